@@ -0,0 +1,163 @@
+package pakay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/log"
+)
+
+// AssertStatus is the availability of a secret.
+type AssertStatus string
+
+const (
+	// StatusFound means one of the sources returned a value.
+	StatusFound AssertStatus = "found"
+	// StatusMissing means none of the sources returned a value.
+	StatusMissing AssertStatus = "missing"
+	// StatusErrored means none of the sources returned a value and at least one failed.
+	StatusErrored AssertStatus = "errored"
+	// StatusTimedOut means the secret couldn't be resolved before the deadline.
+	StatusTimedOut AssertStatus = "timed out"
+	// StatusDefaulted means none of the sources returned a value and the default
+	// value of the secret is used instead.
+	StatusDefaulted AssertStatus = "defaulted"
+)
+
+// SecretReport is the availability of a single secret.
+type SecretReport struct {
+	Name   string
+	Status AssertStatus
+	// Required is false for the secrets declared with `required: false`, which are
+	// never reported as missing.
+	Required bool
+	// Source that resolved the secret when found.
+	Source SourceInfo
+	// Err is the resolution error when not found. See GetSecretE.
+	Err      error
+	Duration time.Duration
+}
+
+// AssertReport is the availability of all the secrets, in manifest order.
+type AssertReport []SecretReport
+
+// Missing returns the names of the required secrets that weren't found nor defaulted,
+// in manifest order.
+func (ar AssertReport) Missing() []string {
+	missing := []string{}
+	for _, sr := range ar {
+		if sr.Required && sr.Status != StatusFound && sr.Status != StatusDefaulted {
+			missing = append(missing, sr.Name)
+		}
+	}
+
+	return missing
+}
+
+func (r *Registry) AssertSecrets(ctx context.Context) ([]string, error) {
+	return r.AssertSecretsWithOptions(ctx, AssertOptions{})
+}
+
+// AssertSecretsWithOptions asserts the availability of the secrets loaded in the registry
+// and returns the names of the missing ones in manifest order.
+func (r *Registry) AssertSecretsWithOptions(ctx context.Context, opts AssertOptions) ([]string, error) {
+	report, err := r.AssertSecretsReport(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return report.Missing(), nil
+}
+
+// AssertSecretsReport asserts the availability of the secrets loaded in the registry
+// reporting the status of each of them in manifest order.
+func (r *Registry) AssertSecretsReport(ctx context.Context, opts AssertOptions) (AssertReport, error) {
+	st := r.store.State()
+	if st == nil {
+		return nil, ErrNotLoaded
+	}
+
+	if opts.Profile != "" {
+		if _, ok := st.Profile(opts.Profile); !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, opts.Profile)
+		}
+	}
+
+	filterIn, err := withSelector(opts.FilterIn, opts.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx = log.NewContext(ctx, r.log())
+	ss := st.All()
+	report := make(AssertReport, len(ss))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, s := range ss {
+		report[i] = SecretReport{Name: s.Name, Required: s.IsRequired()}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			report[i].Status = contextStatus(ctx.Err())
+			report[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			start := time.Now()
+			res, err := r.resolve(ctx, s, resolveOptions{SecretOptions: SecretOptions{FilterIn: filterIn, Profile: opts.Profile}, state: st})
+			report[i].Duration = time.Since(start)
+
+			switch {
+			case err == nil && res.Defaulted:
+				report[i].Status = StatusDefaulted
+			case err == nil:
+				report[i].Status = StatusFound
+				report[i].Source = res.Source
+			case errors.Is(err, ErrNotFound):
+				report[i].Status = StatusMissing
+				report[i].Err = err
+			case errors.Is(err, context.DeadlineExceeded):
+				report[i].Status = StatusTimedOut
+				report[i].Err = err
+			default:
+				report[i].Status = StatusErrored
+				report[i].Err = err
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report, nil
+}
+
+func contextStatus(err error) AssertStatus {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return StatusTimedOut
+	}
+
+	return StatusErrored
+}
@@ -0,0 +1,165 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertSecretsReport(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: zz_found
+  sources:
+  - type: static
+    static:
+      value: test_value
+- name: mm_missing
+  sources:
+  - type: env
+    env:
+      key: TEST_ASSERT_MISSING_VAR
+- name: aa_errored
+  sources:
+  - type: bash
+    bash:
+      command: exit 1
+- name: kk_timed_out
+  sources:
+  - type: bash
+    bash:
+      command: exec sleep 5
+`)))
+
+	report, err := r.AssertSecretsReport(context.Background(), AssertOptions{
+		Concurrency: 4,
+		Timeout:     200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	statuses := map[string]AssertStatus{}
+	names := []string{}
+	for _, sr := range report {
+		names = append(names, sr.Name)
+		statuses[sr.Name] = sr.Status
+	}
+
+	require.Equal(t, []string{"zz_found", "mm_missing", "aa_errored", "kk_timed_out"}, names)
+	require.Equal(t, map[string]AssertStatus{
+		"zz_found":     StatusFound,
+		"mm_missing":   StatusMissing,
+		"aa_errored":   StatusErrored,
+		"kk_timed_out": StatusTimedOut,
+	}, statuses)
+	require.Equal(t, "static", report[0].Source.Type)
+	require.ErrorIs(t, report[3].Err, context.DeadlineExceeded)
+	require.Equal(t, []string{"mm_missing", "aa_errored", "kk_timed_out"}, report.Missing())
+
+	missing, err := r.AssertSecretsWithOptions(context.Background(), AssertOptions{Timeout: 200 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, []string{"mm_missing", "aa_errored", "kk_timed_out"}, missing)
+}
+
+func TestAssertSecretsConcurrency(t *testing.T) {
+	config := SecretsConfig{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		config = append(config, SecretConfig{
+			Name:    name,
+			Sources: []SecretSource{{TypedConfig: &BashConfig{Command: "sleep 0.2 && echo " + name}}},
+		})
+	}
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecrets(config))
+
+	start := time.Now()
+	missing, err := r.AssertSecretsWithOptions(context.Background(), AssertOptions{Concurrency: 4})
+	require.NoError(t, err)
+	require.Empty(t, missing)
+	require.Less(t, time.Since(start), 600*time.Millisecond)
+}
+
+func TestAssertSecretsOptional(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: sentry_dsn
+  required: false
+  sources:
+  - type: env
+    env:
+      key: TEST_ASSERT_SENTRY_DSN
+- name: log_level
+  default: info
+  sources:
+  - type: env
+    env:
+      key: TEST_ASSERT_LOG_LEVEL
+- name: api_token
+  sources:
+  - type: env
+    env:
+      key: TEST_ASSERT_API_TOKEN
+`)))
+
+	ctx := context.Background()
+	report, err := r.AssertSecretsReport(ctx, AssertOptions{})
+	require.NoError(t, err)
+	require.Equal(t, StatusMissing, report[0].Status)
+	require.False(t, report[0].Required)
+	require.Equal(t, StatusDefaulted, report[1].Status)
+	require.True(t, report[1].Required)
+	require.Equal(t, StatusMissing, report[2].Status)
+	require.Equal(t, []string{"api_token"}, report.Missing())
+
+	val, ok := r.GetSecret(ctx, "log_level")
+	require.True(t, ok)
+	require.Equal(t, "info", val)
+
+	t.Setenv("TEST_ASSERT_LOG_LEVEL", "debug")
+	res, err := r.ResolveSecret(ctx, "log_level")
+	require.NoError(t, err)
+	require.Equal(t, "debug", res.Value)
+	require.False(t, res.Defaulted)
+
+	_, ok = r.GetSecret(ctx, "sentry_dsn")
+	require.False(t, ok)
+}
+
+func TestDefaultAfterFailedSources(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: log_level
+  default: info
+  sources:
+  - type: bash
+    bash:
+      command: exit 1
+- name: log_config
+  derived:
+    template: level={{ secret "log_level" }}
+`)))
+
+	ctx := context.Background()
+	res, err := r.ResolveSecret(ctx, "log_level")
+	require.NoError(t, err)
+	require.Equal(t, "info", res.Value)
+	require.True(t, res.Defaulted)
+
+	report, err := r.AssertSecretsReport(ctx, AssertOptions{})
+	require.NoError(t, err)
+	require.Equal(t, StatusDefaulted, report[0].Status)
+	require.Empty(t, report.Missing())
+
+	val, err := r.GetSecretE(ctx, "log_config")
+	require.NoError(t, err)
+	require.Equal(t, "level=info", val)
+
+	trace, err := r.Explain(ctx, "log_level", ExplainOptions{})
+	require.NoError(t, err)
+	require.True(t, trace.Defaulted)
+	require.NoError(t, trace.Err)
+	require.Equal(t, OutcomeError, trace.Steps[0].Outcome)
+	require.ErrorContains(t, trace.Steps[0].Err, "exit status 1")
+}
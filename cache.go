@@ -0,0 +1,101 @@
+package pakay
+
+import (
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/secrets"
+	"github.com/jcchavezs/pakay/internal/securemem"
+)
+
+// cachedResolution is a resolution held by the cache. In secure mode the value is
+// held in buf instead of the resolution so it can be wiped on eviction.
+type cachedResolution struct {
+	Resolution
+	buf *securemem.Buffer
+}
+
+// newCachedResolution prepares a resolution for caching. It returns false when the
+// value can't be held in secure memory, in which case it must not be cached.
+func (r *Registry) newCachedResolution(res Resolution) (cachedResolution, bool) {
+	if !r.secureMemory() {
+		return cachedResolution{Resolution: res}, true
+	}
+
+	buf, err := securemem.New([]byte(res.Value))
+	if err != nil {
+		r.log().Warn("Failed to allocate secure memory, value won't be cached", "error", err)
+		return cachedResolution{}, false
+	}
+
+	res.Value = ""
+	return cachedResolution{Resolution: res, buf: buf}, true
+}
+
+// resolution returns the cached resolution. It returns false when its value was
+// already wiped.
+func (c cachedResolution) resolution() (Resolution, bool) {
+	if c.buf == nil {
+		return c.Resolution, true
+	}
+
+	value, err := c.buf.Copy()
+	if err != nil {
+		return Resolution{}, false
+	}
+
+	res := c.Resolution
+	res.Value = string(value)
+	securemem.Wipe(value)
+	return res, true
+}
+
+// destroy wipes the value of a resolution leaving the cache.
+func (c cachedResolution) destroy() {
+	if c.buf != nil {
+		_ = c.buf.Destroy()
+	}
+}
+
+// cacheTTLFor returns how long a resolved value of the secret can be cached, zero
+// meaning it can't.
+func (r *Registry) cacheTTLFor(s secrets.Secret) time.Duration {
+	if s.Cache != nil {
+		return s.Cache.TTL
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cacheTTL
+}
+
+// cacheExpiry returns when a resolved value should be evicted from the cache, which
+// is never after the value itself expires.
+func cacheExpiry(res Resolution, ttl time.Duration) time.Time {
+	expiresAt := time.Now().Add(ttl)
+	if exp := res.Metadata.ExpiresAt; !exp.IsZero() && exp.Before(expiresAt) {
+		return exp
+	}
+
+	return expiresAt
+}
+
+// Invalidate evicts the cached value of the given secret, and of the secrets derived
+// from it, so the next lookup resolves it again, e.g. after the value was rejected
+// by the server it is sent to.
+func (r *Registry) Invalidate(name string) {
+	st := r.store.State()
+	if st == nil {
+		return
+	}
+
+	if s, ok := st.Get(name); ok {
+		name = s.Name
+	}
+
+	r.cache.Delete(append([]string{name}, st.Dependents(name)...)...)
+}
+
+// InvalidateAll evicts all the cached values.
+func (r *Registry) InvalidateAll() {
+	r.cache.Clear()
+}
@@ -0,0 +1,142 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/pakay/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingRegistry returns a registry with a "fixed" source that counts its invocations.
+func newCountingRegistry(calls *int, metadata types.SecretMetadata) *Registry {
+	r := NewRegistry()
+	r.RegisterSource(types.SecretSource{
+		ConfigFactory: func() types.SourceConfig {
+			return &fixedConfig{}
+		},
+		SecretResolverFactory: func(cfg types.SourceConfig) (types.SecretResolver, error) {
+			val := cfg.(*fixedConfig).Value
+			return func(context.Context) types.SecretResult {
+				*calls++
+				return types.SecretResult{Value: val, Found: true, Metadata: metadata}
+			}, nil
+		},
+	})
+
+	return r
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	config := `---
+- name: cached
+  sources:
+  - type: fixed
+    fixed:
+      value: cached_value
+- name: not_cached
+  cache:
+    ttl: 0s
+  sources:
+  - type: fixed
+    fixed:
+      value: not_cached_value
+`
+
+	t.Run("disabled by default", func(t *testing.T) {
+		calls := 0
+		r := newCountingRegistry(&calls, types.SecretMetadata{})
+		require.NoError(t, r.LoadSecretsConfig([]byte(config)))
+
+		for i := 0; i < 3; i++ {
+			_, ok := r.GetSecret(ctx, "cached")
+			require.True(t, ok)
+		}
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("caches and invalidates", func(t *testing.T) {
+		calls := 0
+		r := newCountingRegistry(&calls, types.SecretMetadata{})
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(config), LoadConfigOptions{
+			LoadOptions: LoadOptions{Cache: CacheOptions{TTL: time.Minute}},
+		}))
+
+		for i := 0; i < 3; i++ {
+			val, ok := r.GetSecret(ctx, "cached")
+			require.True(t, ok)
+			require.Equal(t, "cached_value", val)
+		}
+		require.Equal(t, 1, calls)
+
+		r.Invalidate("cached")
+		_, _ = r.GetSecret(ctx, "cached")
+		require.Equal(t, 2, calls)
+
+		r.InvalidateAll()
+		_, _ = r.GetSecret(ctx, "cached")
+		require.Equal(t, 3, calls)
+
+		// per secret configuration overrides the registry one
+		_, _ = r.GetSecret(ctx, "not_cached")
+		_, _ = r.GetSecret(ctx, "not_cached")
+		require.Equal(t, 5, calls)
+
+		// lookups with their own filter are not cached
+		_, _ = r.GetSecretWithOptions(ctx, "cached", SecretOptions{FilterIn: func(Source) bool { return true }})
+		require.Equal(t, 6, calls)
+	})
+
+	t.Run("per secret ttl", func(t *testing.T) {
+		calls := 0
+		r := newCountingRegistry(&calls, types.SecretMetadata{})
+		require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: cached
+  cache:
+    ttl: 10m
+  sources:
+  - type: fixed
+    fixed:
+      value: cached_value
+`)))
+
+		_, _ = r.GetSecret(ctx, "cached")
+		_, _ = r.GetSecret(ctx, "cached")
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("does not outlive the value", func(t *testing.T) {
+		calls := 0
+		r := newCountingRegistry(&calls, types.SecretMetadata{ExpiresAt: time.Now().Add(-time.Second)})
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(config), LoadConfigOptions{
+			LoadOptions: LoadOptions{Cache: CacheOptions{TTL: time.Minute}},
+		}))
+
+		_, _ = r.GetSecret(ctx, "cached")
+		_, _ = r.GetSecret(ctx, "cached")
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("reload evicts changed secrets", func(t *testing.T) {
+		calls := 0
+		r := newCountingRegistry(&calls, types.SecretMetadata{})
+		opts := LoadConfigOptions{LoadOptions: LoadOptions{Cache: CacheOptions{TTL: time.Minute}}}
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(config), opts))
+
+		_, _ = r.GetSecret(ctx, "cached")
+		require.NoError(t, r.ReloadWithOptions([]byte(`---
+- name: cached
+  sources:
+  - type: fixed
+    fixed:
+      value: new_value
+`), opts))
+
+		val, ok := r.GetSecret(ctx, "cached")
+		require.True(t, ok)
+		require.Equal(t, "new_value", val)
+		require.Equal(t, 2, calls)
+	})
+}
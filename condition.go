@@ -0,0 +1,41 @@
+package pakay
+
+import (
+	"github.com/jcchavezs/pakay/internal/parser"
+)
+
+// Condition restricts a source to the environments where all its predicates hold.
+// It is evaluated every time the source is about to be invoked, and the source is
+// skipped when it doesn't hold.
+type Condition struct {
+	// Env holds the environment variables that must be set to the given values,
+	// e.g. {"CI": "true"}.
+	Env map[string]string
+	// EnvSet holds the environment variables that must be set and not empty.
+	EnvSet []string
+	// FileExists holds the paths that must exist, environment variables are expanded.
+	FileExists []string
+	// OnPath holds the binaries that must be found in PATH, e.g. "op".
+	OnPath []string
+	// TTY tells whether stdin must be a terminal or not.
+	TTY *bool
+	// OS and Arch hold the allowed values of GOOS and GOARCH.
+	OS   []string
+	Arch []string
+}
+
+func (c *Condition) toParser() *parser.Condition {
+	if c == nil {
+		return nil
+	}
+
+	return &parser.Condition{
+		Env:        c.Env,
+		EnvSet:     c.EnvSet,
+		FileExists: c.FileExists,
+		OnPath:     c.OnPath,
+		TTY:        c.TTY,
+		OS:         c.OS,
+		Arch:       c.Arch,
+	}
+}
@@ -0,0 +1,52 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceCondition(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  sources:
+  - type: static
+    when:
+      env:
+        TEST_CONDITION_CI: "true"
+    static:
+      value: ci_value
+  - type: static
+    static:
+      value: local_value
+`)))
+
+	val, err := r.GetSecretE(ctx, "api_token")
+	require.NoError(t, err)
+	require.Equal(t, "local_value", val)
+
+	trace, err := r.Explain(ctx, "api_token", ExplainOptions{})
+	require.NoError(t, err)
+	require.Equal(t, OutcomeSkipped, trace.Steps[0].Outcome)
+	require.Equal(t, `condition false: TEST_CONDITION_CI is not "true"`, trace.Steps[0].Reason)
+	require.Equal(t, OutcomeResolved, trace.Steps[1].Outcome)
+
+	// conditions are evaluated at resolution time
+	t.Setenv("TEST_CONDITION_CI", "true")
+
+	val, err = r.GetSecretE(ctx, "api_token")
+	require.NoError(t, err)
+	require.Equal(t, "ci_value", val)
+
+	err = NewRegistry().LoadSecrets(SecretsConfig{{
+		Name: "api_token",
+		Sources: []SecretSource{{
+			TypedConfig: &StaticConfig{Value: "value"},
+			When:        &Condition{OnPath: []string{""}},
+		}},
+	}})
+	require.ErrorContains(t, err, `invalid condition for static source of "api_token"`)
+}
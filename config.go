@@ -1,6 +1,9 @@
 package pakay
 
 import (
+	"maps"
+	"time"
+
 	internaltypes "github.com/jcchavezs/pakay/internal/types"
 	"github.com/jcchavezs/pakay/types"
 
@@ -12,6 +15,49 @@ import (
 type SecretSource struct {
 	internaltypes.TypedConfig
 	Labels []string
+	// LabelValues are the key/value labels of the source, e.g. env=ci, which can
+	// be matched by selectors. The `key=value` items of Labels are added to them.
+	LabelValues map[string]string
+	// Policy configures the timeout and retries of the source.
+	Policy *SourcePolicy
+	// Transform is applied in order to the values returned by the source.
+	Transform []TransformStep
+	// When restricts the source to the environments where the condition holds.
+	When *Condition
+}
+
+// TransformStep transforms the value returned by a source. Name is one of
+// base64_decode, base64_encode, json_path, yaml_path, trim, regex_extract,
+// prefix, suffix or first_line, and Arg is the path, regex, prefix or suffix
+// for the steps taking one.
+type TransformStep struct {
+	Name string
+	Arg  string
+}
+
+// labelValues returns the key/value labels of a source, the ones declared as
+// `key=value` items of its labels overridden by the given values.
+func labelValues(labels []string, values map[string]string) map[string]string {
+	lv := parser.LabelValues(labels)
+	if lv == nil {
+		return values
+	}
+
+	maps.Copy(lv, values)
+	return lv
+}
+
+func toParserTransform(steps []TransformStep) []parser.TransformStep {
+	if steps == nil {
+		return nil
+	}
+
+	out := make([]parser.TransformStep, 0, len(steps))
+	for _, s := range steps {
+		out = append(out, parser.TransformStep{Name: s.Name, Arg: s.Arg})
+	}
+
+	return out
 }
 
 // SecretConfig represents a single secret definition including its sources and metadata.
@@ -19,6 +65,46 @@ type SecretConfig struct {
 	Name        string
 	Description string
 	Sources     []SecretSource
+	// Cache overrides the cache options of the registry for this secret.
+	Cache *CacheConfig
+	// Deadline caps the time spent resolving the secret across all its sources.
+	Deadline time.Duration
+	// Required tells whether AssertSecrets reports the secret as missing when none
+	// of its sources returns a value. Defaults to true.
+	Required *bool
+	// Default is the value used when none of the sources returns one.
+	Default *string
+	// Validate declares the rules the values returned by the sources must satisfy.
+	Validate *ValidationRules
+	// Derived declares the value of the secret as a template over other secrets
+	// instead of sources.
+	Derived *DerivedConfig
+	// Aliases are former names of the secret that keep resolving to it. Looking
+	// the secret up by an alias logs a warning.
+	Aliases []string
+	// Deprecated flags the secret as going away. Looking it up logs a warning.
+	Deprecated *Deprecation
+}
+
+// Deprecation flags a secret that is going away.
+type Deprecation struct {
+	// Since is the version or date the secret was deprecated in.
+	Since string
+	// Replacement is the name of the secret to use instead.
+	Replacement string
+	Message     string
+}
+
+// DerivedConfig configures a secret composed from other secrets.
+type DerivedConfig struct {
+	// Template referencing other secrets, e.g. `postgres://{{ secret "db_user" }}@db/app`.
+	Template string
+}
+
+// CacheConfig configures the caching of a resolved secret.
+type CacheConfig struct {
+	// TTL of the cached value, zero disables caching.
+	TTL time.Duration
 }
 
 // SecretsConfig groups multiple secret definitions that together form a manifest.
@@ -31,15 +117,41 @@ func (ssc SecretsConfig) toManifestEntries() []parser.ManifestEntry {
 		me := parser.ManifestEntry{
 			Name:        sc.Name,
 			Description: sc.Description,
-			Sources:     make([]parser.ManifestEntrySource, 0, len(sc.Sources)),
+			Deadline:    sc.Deadline,
+			Required:    sc.Required,
+			Default:     sc.Default,
+			Validate:    sc.Validate.toParser(),
+			Aliases:     sc.Aliases,
+		}
+
+		if sc.Deprecated != nil {
+			me.Deprecated = &parser.Deprecation{
+				Since:       sc.Deprecated.Since,
+				Replacement: sc.Deprecated.Replacement,
+				Message:     sc.Deprecated.Message,
+			}
+		}
+
+		if sc.Derived != nil {
+			me.Derived = &parser.DerivedConfig{Template: sc.Derived.Template}
+		}
+
+		if sc.Cache != nil {
+			me.Cache = &parser.CacheConfig{TTL: sc.Cache.TTL}
 		}
 
+		// sources are left nil when there are none, e.g. for derived secrets, the
+		// same way the parser does
 		for _, s := range sc.Sources {
 			c := s.TypedConfig.(types.SourceConfig)
 			me.Sources = append(me.Sources, parser.ManifestEntrySource{
-				Labels: s.Labels,
-				Type:   c.Type(),
-				Config: c,
+				Labels:      s.Labels,
+				LabelValues: labelValues(s.Labels, s.LabelValues),
+				Type:        c.Type(),
+				Policy:      s.Policy.toParser(),
+				Transform:   toParserTransform(s.Transform),
+				When:        s.When.toParser(),
+				Config:      c,
 			})
 		}
 
@@ -2,6 +2,7 @@ package pakay
 
 import (
 	"testing"
+	"time"
 
 	"github.com/jcchavezs/pakay/internal/parser"
 	"github.com/stretchr/testify/require"
@@ -19,23 +20,58 @@ func TestSecretsConfigToManifestEntriesParity(t *testing.T) {
   description: Bash secret
   sources:
     - type: bash
+      transform:
+      - trim
+      - prefix: "Bearer "
+      policy:
+        timeout: 2s
+        max_attempts: 3
+        backoff:
+          initial: 10ms
       bash:
         command: echo hi
         timeout_ms: 1000
 - name: env_secret
+  validate:
+    min_length: 8
+    format: base64
+    on_failure: error
+  required: false
+  default: fallback
+  cache:
+    ttl: 10m
   sources:
     - type: env
+      labels: [ci, env=ci]
       env:
         key: ENV_KEY
 - name: stdin_secret
   description: Stdin secret
+  aliases: [old_stdin_secret]
+  deprecated:
+    since: v2
+    replacement: env_secret
+  deadline: 30s
   sources:
     - type: stdin
+      when:
+        tty: true
+        os: [linux, darwin]
       stdin:
         prompt: enter value
+- name: derived_secret
+  derived:
+    template: '{{ secret "static_secret" }}:{{ secret "env_secret" }}'
 - name: op_secret
   sources:
     - type: 1password
+      when:
+        env:
+          CI: "true"
+        env_set: [OP_SERVICE_ACCOUNT_TOKEN]
+        file_exists: [$HOME/.config/op]
+        on_path: [op]
+        arch: [amd64]
       1password:
         ref: op://vault/item/field
 `
@@ -53,25 +89,51 @@ func TestSecretsConfigToManifestEntriesParity(t *testing.T) {
 			Description: "Bash secret",
 			Sources: []SecretSource{{
 				TypedConfig: &BashConfig{Command: "echo hi", TimeoutMS: 1000},
+				Transform:   []TransformStep{{Name: "trim"}, {Name: "prefix", Arg: "Bearer "}},
+				Policy: &SourcePolicy{
+					Timeout:     2 * time.Second,
+					MaxAttempts: 3,
+					Backoff:     BackoffPolicy{Initial: 10 * time.Millisecond},
+				},
 			}},
 		},
 		{
-			Name: "env_secret",
+			Name:     "env_secret",
+			Cache:    &CacheConfig{TTL: 10 * time.Minute},
+			Required: ptr(false),
+			Default:  ptr("fallback"),
+			Validate: &ValidationRules{MinLength: 8, Format: "base64", OnFailure: "error"},
 			Sources: []SecretSource{{
 				TypedConfig: &EnvConfig{Key: "ENV_KEY"},
+				Labels:      []string{"ci", "env=ci"},
 			}},
 		},
 		{
 			Name:        "stdin_secret",
 			Description: "Stdin secret",
+			Deadline:    30 * time.Second,
+			Aliases:     []string{"old_stdin_secret"},
+			Deprecated:  &Deprecation{Since: "v2", Replacement: "env_secret"},
 			Sources: []SecretSource{{
 				TypedConfig: &StdinConfig{Prompt: "enter value"},
+				When:        &Condition{TTY: ptr(true), OS: []string{"linux", "darwin"}},
 			}},
 		},
+		{
+			Name:    "derived_secret",
+			Derived: &DerivedConfig{Template: `{{ secret "static_secret" }}:{{ secret "env_secret" }}`},
+		},
 		{
 			Name: "op_secret",
 			Sources: []SecretSource{{
 				TypedConfig: &OnePasswordConfig{Ref: "op://vault/item/field"},
+				When: &Condition{
+					Env:        map[string]string{"CI": "true"},
+					EnvSet:     []string{"OP_SERVICE_ACCOUNT_TOKEN"},
+					FileExists: []string{"$HOME/.config/op"},
+					OnPath:     []string{"op"},
+					Arch:       []string{"amd64"},
+				},
 			}},
 		},
 	}.toManifestEntries()
@@ -81,3 +143,7 @@ func TestSecretsConfigToManifestEntriesParity(t *testing.T) {
 
 	require.Equal(t, parsed, programmatic)
 }
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,38 @@
+package pakay
+
+import (
+	"github.com/jcchavezs/pakay/internal/secrets"
+)
+
+// warnDeprecated logs a warning the first time a secret is looked up by an alias
+// or while deprecated. Warnings are logged again after the manifest changes.
+func (r *Registry) warnDeprecated(name string, s secrets.Secret) {
+	if name == s.Name && s.Deprecated == nil {
+		return
+	}
+
+	if _, warned := r.warned.LoadOrStore(name, struct{}{}); warned {
+		return
+	}
+
+	if name != s.Name {
+		r.log().Warn("Secret looked up by an alias", "alias", name, "name", s.Name)
+	}
+
+	if d := s.Deprecated; d != nil {
+		attrs := []any{"name", s.Name}
+		if d.Since != "" {
+			attrs = append(attrs, "since", d.Since)
+		}
+
+		if d.Replacement != "" {
+			attrs = append(attrs, "replacement", d.Replacement)
+		}
+
+		if d.Message != "" {
+			attrs = append(attrs, "message", d.Message)
+		}
+
+		r.log().Warn("Secret is deprecated", attrs...)
+	}
+}
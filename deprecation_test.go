@@ -0,0 +1,83 @@
+package pakay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasesAndDeprecation(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewRegistryWithOptions(RegistryOptions{LogHandler: slog.NewJSONHandler(buf, nil)})
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  aliases: [old_api_token]
+  sources:
+  - type: static
+    static:
+      value: test_value
+- name: legacy_token
+  deprecated:
+    since: v1.2.0
+    replacement: api_token
+    message: The legacy API is going away
+  sources:
+  - type: static
+    static:
+      value: legacy_value
+`)))
+
+	ctx := context.Background()
+	for range 2 {
+		val, ok := r.GetSecret(ctx, "old_api_token")
+		require.True(t, ok)
+		require.Equal(t, "test_value", val)
+
+		val, ok = r.GetSecret(ctx, "legacy_token")
+		require.True(t, ok)
+		require.Equal(t, "legacy_value", val)
+
+		_, ok = r.GetSecret(ctx, "api_token")
+		require.True(t, ok)
+	}
+
+	logs := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		l := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &l))
+		delete(l, "time")
+		logs = append(logs, l)
+	}
+
+	// warnings are logged once
+	require.Equal(t, []map[string]any{
+		{"level": "WARN", "msg": "Secret looked up by an alias", "alias": "old_api_token", "name": "api_token"},
+		{"level": "WARN", "msg": "Secret is deprecated", "name": "legacy_token", "since": "v1.2.0", "replacement": "api_token", "message": "The legacy API is going away"},
+	}, logs)
+
+	report, err := r.AssertSecretsReport(ctx, AssertOptions{})
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+}
+
+func TestAliasesOnLoad(t *testing.T) {
+	err := NewRegistry().LoadSecretsConfig([]byte(`---
+- name: api_token
+  sources:
+  - type: env
+    env:
+      key: API_TOKEN
+- name: new_api_token
+  aliases: [api_token]
+  sources:
+  - type: env
+    env:
+      key: NEW_API_TOKEN
+`))
+	require.EqualError(t, err, `duplicated declaration for "api_token"`)
+}
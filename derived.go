@@ -0,0 +1,60 @@
+package pakay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/jcchavezs/pakay/internal/secrets"
+)
+
+// derivedSourceType is the type reported in SourceInfo for derived secrets.
+const derivedSourceType = "derived"
+
+// derive renders the template of a derived secret resolving the secrets it depends
+// on. A missing dependency makes the derived secret missing too.
+func (r *Registry) derive(ctx context.Context, s secrets.Secret, opts resolveOptions) (Resolution, error) {
+	st := opts.state
+	value, err := s.Template.Execute(func(name string) (string, error) {
+		dep, ok := st.Get(name)
+		if !ok {
+			return "", fmt.Errorf("%w: %q", ErrUnknownSecret, name)
+		}
+
+		// the deadline, if any, is already set in ctx
+		res, err := r.resolve(ctx, dep, resolveOptions{
+			SecretOptions: SecretOptions{FilterIn: opts.FilterIn, Profile: opts.Profile},
+			dryRun:        opts.dryRun,
+			state:         st,
+		})
+		if err != nil {
+			return "", fmt.Errorf("resolving dependency %q: %w", name, err)
+		}
+
+		return res.Value, nil
+	})
+
+	if err == nil && s.Validator != nil {
+		if verr := s.Validator(value); verr != nil {
+			if s.Validate.OnFailure == parser.OnFailureError {
+				return Resolution{}, fmt.Errorf("resolving secret %q: %w: %w", s.Name, ErrInvalidValue, verr)
+			}
+
+			err = fmt.Errorf("%w: invalid value: %w", ErrNotFound, verr)
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrNotFound) && s.Default != nil {
+			opts.trace.defaulted()
+			return Resolution{Value: *s.Default, Defaulted: true}, nil
+		}
+
+		return Resolution{}, fmt.Errorf("resolving secret %q: %w", s.Name, err)
+	}
+
+	info := SourceInfo{Index: -1, Type: derivedSourceType, Description: derivedSourceType + ": " + s.Derived.Template}
+	opts.trace.resolvedBy(info)
+	return Resolution{Value: value, Source: info}, nil
+}
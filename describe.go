@@ -0,0 +1,112 @@
+package pakay
+
+import (
+	"slices"
+
+	"github.com/jcchavezs/pakay/internal/secrets"
+)
+
+// SecretInfo describes a loaded secret without giving access to its value.
+type SecretInfo struct {
+	Name        string
+	Description string
+	// Required is false for the secrets that can be missing.
+	Required bool
+	// Default is the value used when none of the sources returns one, if any.
+	Default *string
+	// Dependencies are the secrets a derived secret is composed from.
+	Dependencies []string
+	Aliases      []string
+	Deprecated   *Deprecation
+	// Sources are the sources selected by the options, in the order they are
+	// declared.
+	Sources []DescribedSource
+}
+
+// DescribedSource describes one of the sources of a secret.
+type DescribedSource struct {
+	SourceInfo
+	// Condition returns an error describing why the condition of the source
+	// doesn't hold, evaluated on every call. It is nil for the sources without one.
+	Condition func() error
+}
+
+// DescribeOptions selects the sources described by DescribeSecrets.
+type DescribeOptions struct {
+	FilterIn FilterIn
+	// Selector is a label selector applied on top of FilterIn, see ParseSelector.
+	// An invalid selector selects no sources.
+	Selector string
+	// Profile selects the sources of one of the profiles declared in the manifest
+	// instead of the ones of the registry profile. An unknown profile selects no
+	// sources.
+	Profile string
+}
+
+// DescribeSecrets describes all the loaded secrets in the order they were declared.
+// It returns ErrNotLoaded when no secrets are loaded.
+func (r *Registry) DescribeSecrets(opts DescribeOptions) ([]SecretInfo, error) {
+	st := r.store.State()
+	if st == nil {
+		return nil, ErrNotLoaded
+	}
+
+	none := func(secrets.Source) bool { return false }
+
+	selectorFilter, err := ParseSelector(opts.Selector)
+	if err != nil {
+		selectorFilter = none
+	}
+
+	filterIn := r.store.Filter(secrets.And(opts.FilterIn, selectorFilter))
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = r.Profile()
+	}
+
+	if profile != "" {
+		profileFilter, ok := st.Profile(profile)
+		if !ok {
+			profileFilter = none
+		}
+
+		filterIn = secrets.And(filterIn, profileFilter)
+	}
+
+	all := st.All()
+	infos := make([]SecretInfo, 0, len(all))
+	for _, s := range all {
+		info := SecretInfo{
+			Name:         s.Name,
+			Description:  s.Description,
+			Required:     s.IsRequired(),
+			Dependencies: s.Dependencies(),
+			Aliases:      slices.Clone(s.Aliases),
+		}
+
+		if s.Default != nil {
+			def := *s.Default
+			info.Default = &def
+		}
+
+		if d := s.Deprecated; d != nil {
+			info.Deprecated = &Deprecation{Since: d.Since, Replacement: d.Replacement, Message: d.Message}
+		}
+
+		for i := range s.Sources {
+			if filterIn != nil && !filterIn(s.Source(i)) {
+				continue
+			}
+
+			info.Sources = append(info.Sources, DescribedSource{
+				SourceInfo: newSourceInfo(s, i),
+				Condition:  s.Getters[i].Condition,
+			})
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,51 @@
+package pakay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeSecrets(t *testing.T) {
+	r := NewRegistry()
+	_, err := r.DescribeSecrets(DescribeOptions{})
+	require.ErrorIs(t, err, ErrNotLoaded)
+
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  default: fallback
+  sources:
+  - type: env
+    labels: [env=ci]
+    when:
+      env_set: [TEST_DESCRIBE_CI]
+    env:
+      key: TEST_DESCRIBE_API_TOKEN
+  - type: static
+    labels: [env=local]
+    static:
+      value: local_value
+`)))
+
+	infos, err := r.DescribeSecrets(DescribeOptions{})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, "api_token", infos[0].Name)
+	require.True(t, infos[0].Required)
+	require.Len(t, infos[0].Sources, 2)
+	require.Equal(t, "env: TEST_DESCRIBE_API_TOKEN", infos[0].Sources[0].Description)
+	require.Error(t, infos[0].Sources[0].Condition())
+	require.Nil(t, infos[0].Sources[1].Condition)
+
+	// the description is a copy of the declaration
+	*infos[0].Default = "changed"
+	infos, err = r.DescribeSecrets(DescribeOptions{Selector: "env=local"})
+	require.NoError(t, err)
+	require.Equal(t, "fallback", *infos[0].Default)
+	require.Len(t, infos[0].Sources, 1)
+	require.Equal(t, 1, infos[0].Sources[0].Index)
+
+	infos, err = r.DescribeSecrets(DescribeOptions{Selector: "env in ("})
+	require.NoError(t, err)
+	require.Empty(t, infos[0].Sources)
+}
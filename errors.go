@@ -0,0 +1,69 @@
+package pakay
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotLoaded is returned when looking up secrets before loading a manifest.
+	ErrNotLoaded = errors.New("secrets haven't been loaded yet")
+	// ErrUnknownSecret is returned when looking up a secret that isn't declared in the manifest.
+	ErrUnknownSecret = errors.New("unknown secret")
+	// ErrNotFound is returned when none of the sources of a secret returned a value.
+	ErrNotFound = errors.New("secret not found")
+	// ErrInvalidValue is returned when a source returns a value that doesn't satisfy
+	// the validation rules of the secret.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrUnknownProfile is returned when selecting a profile that isn't declared in
+	// the manifest.
+	ErrUnknownProfile = errors.New("unknown profile")
+	// ErrInvalidSelector is returned when a label selector can't be parsed.
+	ErrInvalidSelector = errors.New("invalid selector")
+	// ErrTransform is returned when the value returned by a source can't be transformed.
+	ErrTransform = errors.New("transforming value")
+)
+
+// SourceError is the failure of a single source of a secret.
+type SourceError struct {
+	// Index of the source in the secret declaration.
+	Index int
+	// Type of the source, e.g. "env".
+	Type string
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("source #%d (%s): %v", e.Index, e.Type, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// ResolutionError is returned when a secret couldn't be resolved and at least one
+// of its sources failed, as opposed to merely returning no value. It wraps the
+// error of every failed source so they can be inspected with errors.Is and errors.As.
+type ResolutionError struct {
+	Name   string
+	Errors []*SourceError
+}
+
+func (e *ResolutionError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("resolving secret %q: %s", e.Name, strings.Join(msgs, "; "))
+}
+
+func (e *ResolutionError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
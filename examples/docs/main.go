@@ -42,8 +42,8 @@ var rootCmd = &cobra.Command{
 		cmd.Println("## Secrets")
 		cmd.Println("The following table lists all the secrets available in your application:")
 		cmd.Println("")
-		cmd.Println("| Name | Description | Sources |")
-		cmd.Println("| -------- | -------- | --------")
+		cmd.Println("| Name | Description | Required | Default | Sources |")
+		cmd.Println("| -------- | -------- | -------- | -------- | --------")
 
 		for _, s := range ss {
 			fmtSources := make([]string, 0, len(s.Sources()))
@@ -51,7 +51,43 @@ var rootCmd = &cobra.Command{
 				head, tail, _ := strings.Cut(src, ": ")
 				fmtSources = append(fmtSources, fmt.Sprintf("%s: `%s`", head, tail))
 			}
-			cmd.Printf("| **%s** | %s | %s |\n", s.Name(), s.Description(), strings.Join(fmtSources, "<br/>"))
+
+			if deps := s.Dependencies(); len(deps) > 0 {
+				fmtSources = append(fmtSources, fmt.Sprintf("derived from: `%s`", strings.Join(deps, "`, `")))
+			}
+			required := "yes"
+			if !s.Required() {
+				required = "no"
+			}
+
+			var fmtDefault string
+			if def, ok := s.Default(); ok {
+				fmtDefault = fmt.Sprintf("`%s`", def)
+			}
+
+			fmtName := fmt.Sprintf("**%s**", s.Name())
+			if aliases := s.Aliases(); len(aliases) > 0 {
+				fmtName += fmt.Sprintf("<br/>aliases: `%s`", strings.Join(aliases, "`, `"))
+			}
+
+			description := s.Description()
+			if d, ok := s.Deprecated(); ok {
+				description = "**Deprecated**"
+				if d.Since != "" {
+					description += " since " + d.Since
+				}
+				if d.Replacement != "" {
+					description += fmt.Sprintf(", use `%s` instead", d.Replacement)
+				}
+				if d.Message != "" {
+					description += ". " + d.Message
+				}
+				if s.Description() != "" {
+					description += "<br/>" + s.Description()
+				}
+			}
+
+			cmd.Printf("| %s | %s | %s | %s | %s |\n", fmtName, description, required, fmtDefault, strings.Join(fmtSources, "<br/>"))
 		}
 
 		return nil
@@ -44,11 +44,12 @@ var rootCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.Printf("Reading your credential from \"op://%s/my_test_credential/username\"...\n", opVault)
 
-		val, found := pakay.GetSecret(cmd.Context(), "my_test_credential")
-		if found {
+		// the value is redacted when printed, use val.Reveal() to access the plaintext
+		val, err := pakay.GetSecretValue(cmd.Context(), "my_test_credential")
+		if err == nil {
 			cmd.PrintErrf("✅ Credential found: %s\n", val)
 		} else {
-			cmd.PrintErrln("🚫 Credential not found")
+			cmd.PrintErrf("🚫 Credential not found: %v\n", err)
 		}
 
 		return nil
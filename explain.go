@@ -0,0 +1,135 @@
+package pakay
+
+import (
+	"context"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/log"
+	"github.com/jcchavezs/pakay/internal/secrets"
+)
+
+// TraceOutcome is the outcome of a single source when resolving a secret.
+type TraceOutcome string
+
+const (
+	// OutcomeFilteredOut means the source was excluded by FilterIn.
+	OutcomeFilteredOut TraceOutcome = "filtered out"
+	// OutcomeSkipped means the source was skipped without being invoked, see TraceStep.Reason.
+	OutcomeSkipped TraceOutcome = "skipped"
+	// OutcomeNotAttempted means the resolution ended before reaching the source.
+	OutcomeNotAttempted TraceOutcome = "not attempted"
+	// OutcomeEmpty means the source was invoked and returned no value.
+	OutcomeEmpty TraceOutcome = "empty"
+	// OutcomeInvalid means the source returned a value that doesn't satisfy the
+	// validation rules of the secret, see TraceStep.Reason.
+	OutcomeInvalid TraceOutcome = "invalid"
+	// OutcomeTimedOut means the source didn't return before its deadline.
+	OutcomeTimedOut TraceOutcome = "timed out"
+	// OutcomeError means the source failed.
+	OutcomeError TraceOutcome = "error"
+	// OutcomeResolved means the source returned the value of the secret.
+	OutcomeResolved TraceOutcome = "resolved"
+)
+
+// TraceStep describes what happened with one of the sources of a secret.
+type TraceStep struct {
+	Source  SourceInfo
+	Outcome TraceOutcome
+	// Reason explains why the source was skipped, not attempted or why its value
+	// was invalid.
+	Reason string
+	// Duration of the source invocation including retries, zero if it wasn't invoked.
+	Duration time.Duration
+	// Attempts is the number of times the source was invoked.
+	Attempts int
+	Err      error
+}
+
+// Trace describes how a secret was resolved, with one step per declared source.
+type Trace struct {
+	Name  string
+	Steps []TraceStep
+	// Resolved is true when one of the sources returned a value, see Source.
+	Resolved bool
+	// Source that resolved the secret, if any.
+	Source SourceInfo
+	// Defaulted is true when none of the sources returned a value and the default
+	// value of the secret was used instead.
+	Defaulted bool
+	// Err is the resolution error, if any. See GetSecretE.
+	Err error
+}
+
+func (t *Trace) record(s secrets.Secret, i int, step TraceStep) {
+	if t == nil {
+		return
+	}
+
+	step.Source = newSourceInfo(s, i)
+	t.Steps = append(t.Steps, step)
+	if step.Outcome == OutcomeResolved {
+		t.Resolved = true
+		t.Source = step.Source
+	}
+}
+
+// notAttempted records all the sources from the given index onwards as not attempted.
+func (t *Trace) notAttempted(s secrets.Secret, from int, reason string) {
+	for i := from; i < len(s.Getters); i++ {
+		t.record(s, i, TraceStep{Outcome: OutcomeNotAttempted, Reason: reason})
+	}
+}
+
+func (t *Trace) resolvedBy(info SourceInfo) {
+	if t != nil {
+		t.Resolved = true
+		t.Source = info
+	}
+}
+
+func (t *Trace) defaulted() {
+	if t != nil {
+		t.Defaulted = true
+	}
+}
+
+type ExplainOptions struct {
+	FilterIn FilterIn
+	// Selector is a label selector applied on top of FilterIn, see ParseSelector.
+	Selector string
+	// Profile selects the sources of one of the profiles declared in the manifest
+	// instead of the registry profile.
+	Profile string
+	// DryRun skips the interactive sources, e.g. stdin, instead of invoking them.
+	DryRun bool
+	// Deadline caps the time spent resolving the secret across all its sources,
+	// on top of the deadline declared in the manifest.
+	Deadline time.Duration
+}
+
+// Explain resolves a secret recording what happened with each of its sources: which
+// ones were filtered out or skipped and why, which ones were attempted, how long they
+// took, what they returned and which one won. The value itself is not returned.
+// The error is only set when the secret can't be looked up, resolution errors
+// are reported in Trace.Err.
+func (r *Registry) Explain(ctx context.Context, name string, opts ExplainOptions) (Trace, error) {
+	s, st, err := r.lookup(name)
+	if err != nil {
+		return Trace{}, err
+	}
+
+	filterIn, err := withSelector(opts.FilterIn, opts.Selector)
+	if err != nil {
+		return Trace{}, err
+	}
+
+	t := &Trace{Name: name}
+	_, t.Err = r.resolve(log.NewContext(ctx, r.log()), s, resolveOptions{
+		SecretOptions: SecretOptions{FilterIn: filterIn, Profile: opts.Profile, Deadline: opts.Deadline},
+		dryRun:        opts.DryRun,
+		trace:         t,
+		state:         st,
+	})
+
+	return *t, nil
+}
@@ -0,0 +1,94 @@
+package pakay
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: env
+    labels: [deprecated]
+    env:
+      key: TEST_EXPLAIN_DEPRECATED_VAR
+  - type: stdin
+    stdin:
+      prompt: Insert the secret
+  - type: bash
+    bash:
+      command: exit 2
+  - type: bash
+    bash:
+      command: exec sleep 1
+      timeout_ms: 10
+  - type: env
+    env:
+      key: TEST_EXPLAIN_MISSING_VAR
+  - type: static
+    static:
+      value: test_value
+  - type: env
+    env:
+      key: TEST_EXPLAIN_LAST_VAR
+`)))
+
+	tr, err := r.Explain(context.Background(), "test_secret", ExplainOptions{
+		FilterIn: func(s Source) bool {
+			return !slices.Contains(s.Labels, "deprecated")
+		},
+		DryRun: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, tr.Err)
+	require.True(t, tr.Resolved)
+	require.Equal(t, 5, tr.Source.Index)
+	require.Equal(t, "static: tes*******", tr.Source.Description)
+
+	outcomes := make([]TraceOutcome, 0, len(tr.Steps))
+	for i, s := range tr.Steps {
+		require.Equal(t, i, s.Source.Index)
+		outcomes = append(outcomes, s.Outcome)
+	}
+
+	require.Equal(t, []TraceOutcome{
+		OutcomeFilteredOut,
+		OutcomeSkipped,
+		OutcomeError,
+		OutcomeTimedOut,
+		OutcomeEmpty,
+		OutcomeResolved,
+		OutcomeNotAttempted,
+	}, outcomes)
+
+	require.Equal(t, "interactive source in dry run", tr.Steps[1].Reason)
+	require.ErrorContains(t, tr.Steps[2].Err, "exit status 2")
+	require.Positive(t, tr.Steps[3].Duration)
+	require.Zero(t, tr.Steps[6].Duration)
+
+	_, err = r.Explain(context.Background(), "unknown_secret", ExplainOptions{})
+	require.ErrorIs(t, err, ErrUnknownSecret)
+}
+
+func TestExplainNotFound(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: env
+    env:
+      key: TEST_EXPLAIN_MISSING_VAR
+`)))
+
+	tr, err := r.Explain(context.Background(), "test_secret", ExplainOptions{})
+	require.NoError(t, err)
+	require.False(t, tr.Resolved)
+	require.ErrorIs(t, tr.Err, ErrNotFound)
+	require.Len(t, tr.Steps, 1)
+	require.Equal(t, OutcomeEmpty, tr.Steps[0].Outcome)
+}
@@ -1,9 +1,41 @@
 package pakay
 
-type Source struct {
-	Type   string
-	Labels []string
-}
+import (
+	"fmt"
+
+	"github.com/jcchavezs/pakay/internal/secrets"
+	"github.com/jcchavezs/pakay/internal/selector"
+)
+
+type Source = secrets.Source
 
 // FilterIn sources that should be considered in the secret evaluation
-type FilterIn func(Source) bool
+type FilterIn = secrets.FilterIn
+
+// ParseSelector parses a label selector into a FilterIn. Selectors are comma separated
+// requirements, all of which must hold for a source to be selected:
+//   - `key` and `!key` select the sources with or without the label.
+//   - `key=value`, `key==value` and `key!=value` compare the value of the label.
+//   - `key in (a,b)` and `key notin (a,b)` compare it against a set of values.
+//
+// Besides the labels, `type` is the type of the source and `interactive` is set for
+// the interactive sources, e.g. `env in (ci,prod),!interactive,type!=static`. An empty
+// selector returns a nil FilterIn.
+func ParseSelector(expr string) (FilterIn, error) {
+	f, err := selector.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSelector, err)
+	}
+
+	return f, nil
+}
+
+// withSelector combines the filter with the one of the selector, if any.
+func withSelector(filterIn FilterIn, expr string) (FilterIn, error) {
+	f, err := ParseSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets.And(filterIn, f), nil
+}
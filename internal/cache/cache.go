@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// Cache is a TTL cache safe for concurrent use. Every invalidation bumps its
+// generation so values computed before an invalidation can be discarded instead
+// of stored.
+type Cache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]entry[V]
+	gen     uint64
+	now     func() time.Time
+	onEvict func(V)
+}
+
+// New returns an empty cache. When not nil, onEvict is called with every value
+// leaving the cache, whether it expired, was overwritten or was invalidated. It
+// is called with the cache locked so it must not call back into the cache.
+func New[V any](onEvict func(V)) *Cache[V] {
+	return &Cache[V]{
+		entries: map[string]entry[V]{},
+		now:     time.Now,
+		onEvict: onEvict,
+	}
+}
+
+func (c *Cache[V]) evict(e entry[V]) {
+	if c.onEvict != nil {
+		c.onEvict(e.value)
+	}
+}
+
+// Get returns the value stored under key if it hasn't expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		c.evict(e)
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Generation returns the current generation of the cache. It must be read before
+// computing a value to be passed to Set.
+func (c *Cache[V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// Set stores the value under key until expiresAt. The value is discarded if the
+// cache was invalidated since gen was read, in which case it returns false.
+func (c *Cache[V]) Set(key string, value V, expiresAt time.Time, gen uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen || !c.now().Before(expiresAt) {
+		return false
+	}
+
+	if prev, ok := c.entries[key]; ok {
+		c.evict(prev)
+	}
+
+	c.entries[key] = entry[V]{value: value, expiresAt: expiresAt}
+	return true
+}
+
+// Delete removes the values stored under the given keys.
+func (c *Cache[V]) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, key := range keys {
+		if e, ok := c.entries[key]; ok {
+			delete(c.entries, key)
+			c.evict(e)
+		}
+	}
+}
+
+// Clear removes all the values.
+func (c *Cache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, e := range c.entries {
+		c.evict(e)
+	}
+	clear(c.entries)
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	var evicted []string
+	c := New(func(v string) { evicted = append(evicted, v) })
+	c.now = func() time.Time { return now }
+
+	t.Run("expires values", func(t *testing.T) {
+		require.True(t, c.Set("a", "value_a", now.Add(time.Minute), c.Generation()))
+
+		v, ok := c.Get("a")
+		require.True(t, ok)
+		require.Equal(t, "value_a", v)
+
+		now = now.Add(time.Minute)
+		_, ok = c.Get("a")
+		require.False(t, ok)
+		require.Equal(t, []string{"value_a"}, evicted)
+	})
+
+	t.Run("does not store expired values", func(t *testing.T) {
+		require.False(t, c.Set("a", "value_a", now, c.Generation()))
+	})
+
+	t.Run("discards values computed before an invalidation", func(t *testing.T) {
+		gen := c.Generation()
+		c.Delete("b")
+		require.False(t, c.Set("a", "value_a", now.Add(time.Minute), gen))
+
+		_, ok := c.Get("a")
+		require.False(t, ok)
+	})
+
+	t.Run("deletes and clears", func(t *testing.T) {
+		evicted = nil
+		require.True(t, c.Set("a", "value_a", now.Add(time.Minute), c.Generation()))
+		require.True(t, c.Set("b", "value_b", now.Add(time.Minute), c.Generation()))
+
+		c.Delete("a")
+		_, ok := c.Get("a")
+		require.False(t, ok)
+		_, ok = c.Get("b")
+		require.True(t, ok)
+
+		c.Clear()
+		_, ok = c.Get("b")
+		require.False(t, ok)
+		require.Equal(t, []string{"value_a", "value_b"}, evicted)
+	})
+
+	t.Run("evicts overwritten values", func(t *testing.T) {
+		evicted = nil
+		require.True(t, c.Set("a", "value_a", now.Add(time.Minute), c.Generation()))
+		require.True(t, c.Set("a", "value_a2", now.Add(time.Minute), c.Generation()))
+		require.Equal(t, []string{"value_a"}, evicted)
+	})
+}
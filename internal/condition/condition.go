@@ -0,0 +1,140 @@
+// Package condition evaluates the `when` conditions declared on the sources.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"golang.org/x/term"
+)
+
+// Condition returns an error describing the first predicate that doesn't hold, nil
+// when all of them do.
+type Condition func() error
+
+type predicate func() error
+
+var (
+	lookupEnv  = os.LookupEnv
+	lookPath   = exec.LookPath
+	stat       = os.Stat
+	isTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+	goos       = runtime.GOOS
+	goarch     = runtime.GOARCH
+)
+
+// New returns the condition made of the given predicates. It returns an error when
+// any of them is empty.
+func New(c parser.Condition) (Condition, error) {
+	var preds []predicate
+
+	for _, key := range slices.Sorted(maps.Keys(c.Env)) {
+		if key == "" {
+			return nil, errors.New("env: empty variable name")
+		}
+
+		want := c.Env[key]
+		preds = append(preds, func() error {
+			if v, _ := lookupEnv(key); v != want {
+				return fmt.Errorf("%s is not %q", key, want)
+			}
+			return nil
+		})
+	}
+
+	for _, key := range c.EnvSet {
+		if key == "" {
+			return nil, errors.New("env_set: empty variable name")
+		}
+
+		preds = append(preds, func() error {
+			if v, _ := lookupEnv(key); v == "" {
+				return fmt.Errorf("%s is not set", key)
+			}
+			return nil
+		})
+	}
+
+	for _, path := range c.FileExists {
+		if path == "" {
+			return nil, errors.New("file_exists: empty path")
+		}
+
+		preds = append(preds, func() error {
+			if _, err := stat(os.ExpandEnv(path)); err != nil {
+				return fmt.Errorf("%s does not exist", path)
+			}
+			return nil
+		})
+	}
+
+	for _, bin := range c.OnPath {
+		if bin == "" {
+			return nil, errors.New("on_path: empty binary name")
+		}
+
+		preds = append(preds, func() error {
+			if _, err := lookPath(bin); err != nil {
+				return fmt.Errorf("%s is not on PATH", bin)
+			}
+			return nil
+		})
+	}
+
+	if c.TTY != nil {
+		want := *c.TTY
+		preds = append(preds, func() error {
+			switch tty := isTerminal(); {
+			case want && !tty:
+				return errors.New("stdin is not a terminal")
+			case !want && tty:
+				return errors.New("stdin is a terminal")
+			}
+			return nil
+		})
+	}
+
+	if p, err := oneOf("os", c.OS, goos); err != nil {
+		return nil, err
+	} else if p != nil {
+		preds = append(preds, p)
+	}
+
+	if p, err := oneOf("arch", c.Arch, goarch); err != nil {
+		return nil, err
+	} else if p != nil {
+		preds = append(preds, p)
+	}
+
+	return func() error {
+		for _, p := range preds {
+			if err := p(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func oneOf(name string, allowed []string, actual string) (predicate, error) {
+	if len(allowed) == 0 {
+		return nil, nil
+	}
+
+	if slices.Contains(allowed, "") {
+		return nil, fmt.Errorf("%s: empty value", name)
+	}
+
+	return func() error {
+		if !slices.Contains(allowed, actual) {
+			return fmt.Errorf("%s is %s", name, actual)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,80 @@
+package condition
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	for name, c := range map[string]parser.Condition{
+		"empty env":     {Env: map[string]string{"": "true"}},
+		"empty env_set": {EnvSet: []string{""}},
+		"empty path":    {FileExists: []string{""}},
+		"empty binary":  {OnPath: []string{""}},
+		"empty os":      {OS: []string{""}},
+		"empty arch":    {Arch: []string{"amd64", ""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(c)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCondition(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), nil, 0o600))
+	t.Setenv("TEST_CONDITION_DIR", dir)
+	t.Setenv("TEST_CONDITION_CI", "true")
+
+	oldLookPath, oldIsTerminal := lookPath, isTerminal
+	t.Cleanup(func() { lookPath, isTerminal = oldLookPath, oldIsTerminal })
+	lookPath = func(bin string) (string, error) {
+		if bin == "op" {
+			return "/usr/bin/op", nil
+		}
+		return "", os.ErrNotExist
+	}
+	isTerminal = func() bool { return false }
+
+	for name, tc := range map[string]struct {
+		cond   parser.Condition
+		reason string
+	}{
+		"no predicates":  {},
+		"env":            {cond: parser.Condition{Env: map[string]string{"TEST_CONDITION_CI": "true"}}},
+		"env mismatch":   {cond: parser.Condition{Env: map[string]string{"TEST_CONDITION_CI": "false"}}, reason: `TEST_CONDITION_CI is not "false"`},
+		"env set":        {cond: parser.Condition{EnvSet: []string{"TEST_CONDITION_CI"}}},
+		"env unset":      {cond: parser.Condition{EnvSet: []string{"TEST_CONDITION_UNSET"}}, reason: "TEST_CONDITION_UNSET is not set"},
+		"file exists":    {cond: parser.Condition{FileExists: []string{"$TEST_CONDITION_DIR/token"}}},
+		"file missing":   {cond: parser.Condition{FileExists: []string{"$TEST_CONDITION_DIR/missing"}}, reason: "$TEST_CONDITION_DIR/missing does not exist"},
+		"on path":        {cond: parser.Condition{OnPath: []string{"op"}}},
+		"not on path":    {cond: parser.Condition{OnPath: []string{"vault"}}, reason: "vault is not on PATH"},
+		"not a tty":      {cond: parser.Condition{TTY: ptr(false)}},
+		"tty":            {cond: parser.Condition{TTY: ptr(true)}, reason: "stdin is not a terminal"},
+		"os":             {cond: parser.Condition{OS: []string{goos}}},
+		"other os":       {cond: parser.Condition{OS: []string{"plan9"}}, reason: "os is " + goos},
+		"arch":           {cond: parser.Condition{Arch: []string{goarch}}},
+		"all predicates": {cond: parser.Condition{EnvSet: []string{"TEST_CONDITION_CI"}, OnPath: []string{"op"}, OS: []string{goos}}},
+		"first to fail":  {cond: parser.Condition{OnPath: []string{"vault"}, OS: []string{"plan9"}}, reason: "vault is not on PATH"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(tc.cond)
+			require.NoError(t, err)
+
+			if tc.reason == "" {
+				require.NoError(t, c())
+			} else {
+				require.EqualError(t, c(), tc.reason)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,148 @@
+// Package derive renders the values of derived secrets, which are templates over
+// other secrets, e.g. `postgres://{{ secret "db_user" }}@{{ secret "db_host" }}/app`.
+package derive
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const secretFunc = "secret"
+
+// Template is a parsed derived secret template.
+type Template struct {
+	tmpl *template.Template
+	// Dependencies are the names of the secrets referenced by the template, in
+	// order of appearance and without duplicates.
+	Dependencies []string
+}
+
+// Parse parses the template of the derived secret with the given name. Secrets must
+// be referenced with string literals so the dependencies are known upfront.
+func Parse(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{secretFunc: func(string) (string, error) {
+			return "", errors.New("secret lookup not bound")
+		}}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	deps := []string{}
+	if err := collect(tmpl.Root, &deps); err != nil {
+		return nil, err
+	}
+
+	if len(deps) == 0 {
+		return nil, errors.New("template doesn't reference any secret")
+	}
+
+	return &Template{tmpl: tmpl, Dependencies: deps}, nil
+}
+
+// collect appends the names of the secrets referenced in the node to deps.
+func collect(node parse.Node, deps *[]string) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+
+		for _, c := range n.Nodes {
+			if err := collect(c, deps); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return collect(n.Pipe, deps)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+
+		for _, cmd := range n.Cmds {
+			if err := collect(cmd, deps); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for i, arg := range n.Args {
+			if id, ok := arg.(*parse.IdentifierNode); ok && id.Ident == secretFunc {
+				if i != 0 || len(n.Args) != 2 {
+					return fmt.Errorf("%s must be called with a single secret name", secretFunc)
+				}
+
+				s, ok := n.Args[1].(*parse.StringNode)
+				if !ok {
+					return fmt.Errorf("%s must be called with a string literal", secretFunc)
+				}
+
+				if !slices.Contains(*deps, s.Text) {
+					*deps = append(*deps, s.Text)
+				}
+
+				continue
+			}
+
+			if err := collect(arg, deps); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return collectBranch(&n.BranchNode, deps)
+	case *parse.RangeNode:
+		return collectBranch(&n.BranchNode, deps)
+	case *parse.WithNode:
+		return collectBranch(&n.BranchNode, deps)
+	case *parse.TemplateNode:
+		return errors.New("nested templates are not supported")
+	}
+
+	return nil
+}
+
+func collectBranch(n *parse.BranchNode, deps *[]string) error {
+	for _, node := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := collect(node, deps); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Execute renders the template looking up the referenced secrets with the given
+// function. Lookup errors are returned as they are.
+func (t *Template) Execute(lookup func(name string) (string, error)) (string, error) {
+	var lookupErr error
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("cloning template: %w", err)
+	}
+
+	tmpl.Funcs(template.FuncMap{secretFunc: func(name string) (string, error) {
+		v, err := lookup(name)
+		if err != nil && lookupErr == nil {
+			lookupErr = err
+		}
+
+		return v, err
+	}})
+
+	out := strings.Builder{}
+	if err := tmpl.Execute(&out, nil); err != nil {
+		if lookupErr != nil {
+			return "", lookupErr
+		}
+
+		return "", fmt.Errorf("executing template: %w", err)
+	}
+
+	return out.String(), nil
+}
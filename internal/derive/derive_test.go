@@ -0,0 +1,49 @@
+package derive
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tmpl, err := Parse("dsn", `postgres://{{ secret "user" }}:{{ secret "password" }}@{{ if true }}{{ secret "host" }}{{ end }}/{{ secret "user" }}`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user", "password", "host"}, tmpl.Dependencies)
+
+	for name, text := range map[string]string{
+		"no references":   `postgres://localhost`,
+		"non literal":     `{{ secret (printf "us%s" "er") }}`,
+		"too many args":   `{{ secret "a" "b" }}`,
+		"invalid syntax":  `{{ secret "a" `,
+		"unknown func":    `{{ lookup "a" }}`,
+		"nested template": `{{ define "x" }}{{ secret "a" }}{{ end }}{{ template "x" }}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse("dsn", text)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tmpl, err := Parse("dsn", `postgres://{{ secret "user" }}:{{ secret "password" }}@db/app`)
+	require.NoError(t, err)
+
+	values := map[string]string{"user": "admin", "password": "s3cr3t"}
+	out, err := tmpl.Execute(func(name string) (string, error) {
+		return values[name], nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "postgres://admin:s3cr3t@db/app", out)
+
+	errMissing := errors.New("missing")
+	_, err = tmpl.Execute(func(name string) (string, error) {
+		if name == "password" {
+			return "", errMissing
+		}
+		return values[name], nil
+	})
+	require.Equal(t, errMissing, err)
+}
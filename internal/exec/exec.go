@@ -23,7 +23,7 @@ func commandContext(ctx context.Context, stderr io.Writer, command string, args
 	cmd.Stdout = &out
 	cmd.Stderr = stderr
 
-	log.Logger.Debug("Executing command", "command", cmd.String())
+	log.FromContext(ctx).Debug("Executing command", "command", cmd.String())
 
 	if err := cmd.Run(); err != nil {
 		return nil, fmt.Errorf("%s: %w", cmd.String(), err)
@@ -0,0 +1,99 @@
+package flight
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+	// deadline of the context passed to fn, zero if it has none.
+	deadline time.Time
+}
+
+// covers returns whether a caller with the given deadline, zero if it has none,
+// can join the call: the call must not end before the caller would give up.
+func (c *call[V]) covers(deadline time.Time) bool {
+	return c.deadline.IsZero() || (!deadline.IsZero() && !deadline.After(c.deadline))
+}
+
+// Group deduplicates concurrent calls sharing the same key: callers arriving while
+// a call is in flight wait for it and get its result instead of starting their own.
+type Group[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+// Do runs fn once for all the concurrent callers of the given key. Each caller
+// stops waiting as soon as its own context is done. The context passed to fn keeps
+// the values and the deadline of the caller that started the call but is only
+// cancelled once every caller has stopped waiting, so one caller giving up doesn't
+// fail the others. Callers willing to wait longer than the deadline of the call in
+// flight start a new one instead of joining it.
+func (g *Group[V]) Do(ctx context.Context, key string, fn func(context.Context) (V, error)) (V, error) {
+	if err := ctx.Err(); err != nil {
+		var zero V
+		return zero, err
+	}
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call[V]{}
+	}
+
+	deadline, _ := ctx.Deadline()
+	c, ok := g.calls[key]
+	if !ok || !c.covers(deadline) {
+		var (
+			fnCtx  context.Context
+			cancel context.CancelFunc
+		)
+		if deadline.IsZero() {
+			fnCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		} else {
+			fnCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		}
+
+		c = &call[V]{done: make(chan struct{}), cancel: cancel, deadline: deadline}
+		g.calls[key] = c
+
+		go func() {
+			c.val, c.err = fn(fnCtx)
+			cancel()
+
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			// later callers start a new call rather than joining a cancelled one
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+
+		var zero V
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,180 @@
+package flight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("shares the result between concurrent callers", func(t *testing.T) {
+		var (
+			g       Group[string]
+			calls   atomic.Int32
+			release = make(chan struct{})
+			wg      sync.WaitGroup
+		)
+
+		results := make([]string, 5)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], _ = g.Do(context.Background(), "key", func(context.Context) (string, error) {
+					calls.Add(1)
+					<-release
+					return "value", nil
+				})
+			}()
+		}
+
+		require.Eventually(t, func() bool {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return g.calls["key"] != nil && g.calls["key"].waiters == len(results)
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+
+		require.Equal(t, int32(1), calls.Load())
+		require.Equal(t, []string{"value", "value", "value", "value", "value"}, results)
+	})
+
+	t.Run("a cancelled caller doesn't cancel the others", func(t *testing.T) {
+		var g Group[string]
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		fn := func(ctx context.Context) (string, error) {
+			close(started)
+			select {
+			case <-release:
+				return "value", nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := g.Do(ctx, "key", fn)
+			errs <- err
+		}()
+		<-started
+
+		res := make(chan string)
+		go func() {
+			v, _ := g.Do(context.Background(), "key", fn)
+			res <- v
+		}()
+
+		require.Eventually(t, func() bool {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return g.calls["key"].waiters == 2
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-errs, context.Canceled)
+
+		close(release)
+		require.Equal(t, "value", <-res)
+	})
+
+	t.Run("the call is cancelled when all callers leave", func(t *testing.T) {
+		var g Group[string]
+		cancelled := make(chan struct{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := g.Do(ctx, "key", func(ctx context.Context) (string, error) {
+				<-ctx.Done()
+				close(cancelled)
+				return "", ctx.Err()
+			})
+			errs <- err
+		}()
+
+		require.Eventually(t, func() bool {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return g.calls["key"] != nil
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-errs, context.Canceled)
+		<-cancelled
+
+		v, err := g.Do(context.Background(), "key", func(context.Context) (string, error) {
+			return "new_value", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "new_value", v)
+	})
+
+	t.Run("keeps the deadline of the caller", func(t *testing.T) {
+		var g Group[time.Time]
+		release := make(chan struct{})
+
+		fn := func(ctx context.Context) (time.Time, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return time.Time{}, errors.New("no deadline")
+			}
+
+			<-release
+			return deadline, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		want, _ := ctx.Deadline()
+
+		first := make(chan time.Time)
+		go func() {
+			d, _ := g.Do(ctx, "key", fn)
+			first <- d
+		}()
+
+		require.Eventually(t, func() bool {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return g.calls["key"] != nil
+		}, time.Second, time.Millisecond)
+
+		// callers giving up earlier join the call, the ones waiting longer don't
+		shorter, cancelShorter := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelShorter()
+
+		joined := make(chan time.Time)
+		go func() {
+			d, _ := g.Do(shorter, "key", fn)
+			joined <- d
+		}()
+
+		require.Eventually(t, func() bool {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return g.calls["key"].waiters == 2
+		}, time.Second, time.Millisecond)
+
+		unbounded := make(chan error)
+		go func() {
+			_, err := g.Do(context.Background(), "key", fn)
+			unbounded <- err
+		}()
+
+		close(release)
+		require.Equal(t, want, <-first)
+		require.Equal(t, want, <-joined)
+		require.EqualError(t, <-unbounded, "no deadline")
+	})
+}
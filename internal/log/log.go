@@ -12,6 +12,23 @@ func SetHandler(handler slog.Handler) {
 	Logger = slog.New(handler)
 }
 
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying the given logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx or the package level Logger
+// if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return Logger
+}
+
 // Ported from slog.DiscardHandler which makes this available from Go 1.24
 var DiscardHandler slog.Handler = discardHandler{}
 
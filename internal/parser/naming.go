@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// CaseUpper converts the name to upper snake case, e.g. MY_API_TOKEN.
+	CaseUpper = "upper"
+	// CaseLower converts the name to lower snake case, e.g. my_api_token.
+	CaseLower = "lower"
+	// CaseKebab converts the name to kebab case, e.g. my-api-token.
+	CaseKebab = "kebab"
+)
+
+// NamingRules derive the configuration of the sources declared without one from the
+// name of their secret, by source type.
+type NamingRules map[string]NamingRule
+
+// NamingRule derives the configuration of a source type from the name of a secret.
+type NamingRule struct {
+	// Prefix is prepended to the converted name, e.g. MYAPP_.
+	Prefix string `yaml:"prefix"`
+	// Case is one of upper, lower or kebab. The name is used as declared when not set.
+	Case string `yaml:"case"`
+	// Config holds the fields of the source configuration where {name} is replaced
+	// by the prefixed and converted name, e.g. ref: op://vault/{name}/password.
+	Config map[string]string `yaml:"config"`
+}
+
+// DefaultNaming derives the key of the env sources from the upper cased name of the
+// secret, e.g. MY_API_TOKEN for my_api_token.
+var DefaultNaming = NamingRules{
+	"env": {Case: CaseUpper, Config: map[string]string{"key": "{name}"}},
+}
+
+// Merge returns the rules with the fields set in the given ones overriding theirs,
+// neither of which is modified.
+func (rs NamingRules) Merge(override NamingRules) NamingRules {
+	out := maps.Clone(rs)
+	if out == nil {
+		out = NamingRules{}
+	}
+
+	for typ, o := range override {
+		r := out[typ]
+		if o.Prefix != "" {
+			r.Prefix = o.Prefix
+		}
+
+		if o.Case != "" {
+			r.Case = o.Case
+		}
+
+		if len(o.Config) > 0 {
+			r.Config = maps.Clone(r.Config)
+			if r.Config == nil {
+				r.Config = map[string]string{}
+			}
+			maps.Copy(r.Config, o.Config)
+		}
+
+		out[typ] = r
+	}
+
+	return out
+}
+
+// Apply sets the configuration of the sources declared without one, i.e. whose
+// configuration is the zero one of their type, to the one derived from the name
+// of their secret.
+func (rs NamingRules) Apply(ss []ManifestEntry, lookup SourceLookup) error {
+	for i := range ss {
+		for j, src := range ss[i].Sources {
+			p, ok := lookup(src.Type)
+			if !ok || !reflect.DeepEqual(src.Config, p.ConfigFactory()) {
+				continue
+			}
+
+			r, ok := rs[src.Type]
+			if !ok || len(r.Config) == 0 {
+				return fmt.Errorf("%s source of %q has no configuration and there is no naming rule for it", src.Type, ss[i].Name)
+			}
+
+			name, err := r.name(ss[i].Name)
+			if err != nil {
+				return fmt.Errorf("naming rule for %s sources: %w", src.Type, err)
+			}
+
+			fields := make(map[string]string, len(r.Config))
+			for k, v := range r.Config {
+				fields[k] = strings.ReplaceAll(v, "{name}", name)
+			}
+
+			data, err := yaml.Marshal(fields)
+			if err != nil {
+				return fmt.Errorf("marshaling derived configuration: %w", err)
+			}
+
+			cfg := p.ConfigFactory()
+			if err := yaml.Unmarshal(data, cfg); err != nil {
+				return fmt.Errorf("naming rule for %s sources: %w", src.Type, err)
+			}
+
+			ss[i].Sources[j].Config = cfg
+		}
+	}
+
+	return nil
+}
+
+func (r NamingRule) name(secret string) (string, error) {
+	words := strings.FieldsFunc(secret, func(c rune) bool {
+		return c == '_' || c == '-' || c == '.' || c == ' '
+	})
+
+	switch r.Case {
+	case "":
+		return r.Prefix + secret, nil
+	case CaseUpper:
+		return r.Prefix + strings.ToUpper(strings.Join(words, "_")), nil
+	case CaseLower:
+		return r.Prefix + strings.ToLower(strings.Join(words, "_")), nil
+	case CaseKebab:
+		return r.Prefix + strings.ToLower(strings.Join(words, "-")), nil
+	default:
+		return "", fmt.Errorf("unknown case: %s", r.Case)
+	}
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+)
+
+// PatchDelete removes the source of an overlay from the secret.
+const PatchDelete = "delete"
+
+// Overlay changes the sources of a secret declared in the manifest. Sources are keyed
+// by their type: an overlay source replaces the source of the same type or, if the
+// secret has none, is appended to its sources. Sources with `$patch: delete` are
+// removed instead. Order lists the types of the sources that go first, in that
+// order, followed by the remaining ones.
+//
+//	---
+//	- name: api_token
+//	  sources:
+//	  - type: env
+//	    env:
+//	      key: CI_API_TOKEN
+//	  - type: stdin
+//	    $patch: delete
+//	  order: [env, 1password]
+type Overlay struct {
+	Name    string          `yaml:"name"`
+	Sources []OverlaySource `yaml:"sources"`
+	Order   []string        `yaml:"order"`
+}
+
+// OverlaySource is a source of an overlay.
+type OverlaySource struct {
+	ManifestEntrySource
+	// Patch is either empty, to add or replace the source, or delete.
+	Patch string
+}
+
+func (s *OverlaySource) UnmarshalYAML(ctx context.Context, data []byte) error {
+	t := struct {
+		Type  string `yaml:"type"`
+		Patch string `yaml:"$patch"`
+	}{}
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return fmt.Errorf("unmarshaling type: %w", err)
+	}
+
+	switch t.Patch {
+	case "":
+		return s.ManifestEntrySource.UnmarshalYAML(ctx, data)
+	case PatchDelete:
+		if t.Type == "" {
+			return errors.New("missing source type")
+		}
+
+		s.Type, s.Patch = t.Type, t.Patch
+		return nil
+	default:
+		return fmt.Errorf("unknown patch: %s", t.Patch)
+	}
+}
+
+// applyOverlay parses the overlay document and applies it to the secrets.
+func applyOverlay(ctx context.Context, ss []ManifestEntry, overlay []byte, vars map[string]string) error {
+	rOverlay, err := render(overlay, vars)
+	if err != nil {
+		return err
+	}
+
+	var overlays []Overlay
+	if err := yaml.UnmarshalContext(ctx, rOverlay, &overlays, yaml.DisallowUnknownField()); err != nil {
+		return fmt.Errorf("unmarshaling overlay: %w", err)
+	}
+
+	for _, o := range overlays {
+		i := slices.IndexFunc(ss, func(e ManifestEntry) bool { return e.Name == o.Name })
+		if i < 0 {
+			return fmt.Errorf("unknown secret %q", o.Name)
+		}
+
+		sources, err := o.apply(ss[i].Sources)
+		if err != nil {
+			return fmt.Errorf("secret %q: %w", o.Name, err)
+		}
+
+		ss[i].Sources = sources
+	}
+
+	return nil
+}
+
+// apply returns the sources resulting from applying the overlay to the given ones,
+// which are left untouched.
+func (o Overlay) apply(sources []ManifestEntrySource) ([]ManifestEntrySource, error) {
+	sources = slices.Clone(sources)
+
+	for _, src := range o.Sources {
+		i, err := indexOfType(sources, src.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case src.Patch == PatchDelete && i < 0:
+			return nil, fmt.Errorf("no %s source to delete", src.Type)
+		case src.Patch == PatchDelete:
+			sources = slices.Delete(sources, i, i+1)
+		case i < 0:
+			sources = append(sources, src.ManifestEntrySource)
+		default:
+			sources[i] = src.ManifestEntrySource
+		}
+	}
+
+	if len(o.Order) == 0 {
+		return sources, nil
+	}
+
+	ordered := make([]ManifestEntrySource, 0, len(sources))
+	for _, typ := range o.Order {
+		i, err := indexOfType(sources, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		if i < 0 {
+			return nil, fmt.Errorf("no %s source to order", typ)
+		}
+
+		ordered = append(ordered, sources[i])
+		sources = slices.Delete(sources, i, i+1)
+	}
+
+	return append(ordered, sources...), nil
+}
+
+// indexOfType returns the index of the source of the given type, -1 if there is
+// none. Sources are keyed by their type so it fails when there are several.
+func indexOfType(sources []ManifestEntrySource, typ string) (int, error) {
+	i := slices.IndexFunc(sources, func(s ManifestEntrySource) bool { return s.Type == typ })
+	if i < 0 {
+		return -1, nil
+	}
+
+	if slices.ContainsFunc(sources[i+1:], func(s ManifestEntrySource) bool { return s.Type == typ }) {
+		return -1, fmt.Errorf("several %s sources, overlays can't tell them apart", typ)
+	}
+
+	return i, nil
+}
@@ -2,9 +2,14 @@ package parser
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
 	"github.com/jcchavezs/pakay/internal/sources"
@@ -14,23 +19,172 @@ import (
 type ManifestEntrySource struct {
 	Type   string   `yaml:"type"`
 	Labels []string `yaml:"labels"`
+	// LabelValues are the key/value labels, declared as `key=value` items in the
+	// labels list, which are kept in Labels as well, or as a labels mapping.
+	LabelValues map[string]string `yaml:"-"`
+	Policy      *SourcePolicy     `yaml:"policy"`
+	// Transform is applied in order to the values returned by the source.
+	Transform []TransformStep `yaml:"transform"`
+	// When restricts the source to the environments where the condition holds.
+	When   *Condition `yaml:"when"`
 	Config types.SourceConfig
 }
 
+// Condition holds when all its predicates hold. It is evaluated every time the
+// source is about to be invoked.
+type Condition struct {
+	// Env holds the environment variables that must be set to the given values.
+	Env map[string]string `yaml:"env"`
+	// EnvSet holds the environment variables that must be set and not empty.
+	EnvSet []string `yaml:"env_set"`
+	// FileExists holds the paths that must exist, environment variables are expanded.
+	FileExists []string `yaml:"file_exists"`
+	// OnPath holds the binaries that must be found in PATH.
+	OnPath []string `yaml:"on_path"`
+	// TTY tells whether stdin must be a terminal or not.
+	TTY *bool `yaml:"tty"`
+	// OS and Arch hold the allowed values of GOOS and GOARCH.
+	OS   []string `yaml:"os"`
+	Arch []string `yaml:"arch"`
+}
+
+const (
+	TransformBase64Decode = "base64_decode"
+	TransformBase64Encode = "base64_encode"
+	TransformJSONPath     = "json_path"
+	TransformYAMLPath     = "yaml_path"
+	TransformTrim         = "trim"
+	TransformRegexExtract = "regex_extract"
+	TransformPrefix       = "prefix"
+	TransformSuffix       = "suffix"
+	TransformFirstLine    = "first_line"
+)
+
+// TransformStep is a single transformation, declared either as its name, e.g.
+// `- trim`, or as its name and argument, e.g. `- json_path: $.data.token`.
+type TransformStep struct {
+	Name string
+	Arg  string
+}
+
+func (s *TransformStep) UnmarshalYAML(data []byte) error {
+	var name string
+	if err := yaml.Unmarshal(data, &name); err == nil {
+		s.Name = name
+		return nil
+	}
+
+	var m map[string]string
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return errors.New("transform step must be a name or a single name: argument pair")
+	}
+
+	if len(m) != 1 {
+		return fmt.Errorf("transform step must have a single name, got %d", len(m))
+	}
+
+	for name, arg := range m {
+		s.Name, s.Arg = name, arg
+	}
+
+	return nil
+}
+
+// SourcePolicy configures how a source is invoked.
+type SourcePolicy struct {
+	// Timeout of each attempt.
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	Backoff     BackoffPolicy `yaml:"backoff"`
+}
+
+// BackoffPolicy configures the wait between attempts.
+type BackoffPolicy struct {
+	Initial    time.Duration `yaml:"initial"`
+	Max        time.Duration `yaml:"max"`
+	Multiplier float64       `yaml:"multiplier"`
+}
+
+func (p *SourcePolicy) Validate() error {
+	switch {
+	case p.Timeout < 0:
+		return errors.New("timeout cannot be negative")
+	case p.MaxAttempts < 0:
+		return errors.New("max_attempts cannot be negative")
+	case p.Backoff.Initial < 0 || p.Backoff.Max < 0:
+		return errors.New("backoff durations cannot be negative")
+	case p.Backoff.Multiplier != 0 && p.Backoff.Multiplier < 1:
+		return errors.New("backoff multiplier cannot be less than 1")
+	}
+
+	return nil
+}
+
+// labels are either a list of plain and `key=value` labels or a mapping of key/value
+// labels. The items of the list are kept as they are, the `key=value` ones are also
+// made key/value labels.
+type labels struct {
+	list   []string
+	values map[string]string
+}
+
+// LabelValues returns the key/value labels among the given ones, e.g. env=ci, nil if
+// there are none.
+func LabelValues(labels []string) map[string]string {
+	var values map[string]string
+	for _, label := range labels {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok {
+			continue
+		}
+
+		if values == nil {
+			values = map[string]string{}
+		}
+		values[k] = v
+	}
+
+	return values
+}
+
+func (l *labels) UnmarshalYAML(data []byte) error {
+	var values map[string]string
+	if err := yaml.Unmarshal(data, &values); err == nil {
+		l.values = values
+		return nil
+	}
+
+	var list []string
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return errors.New("labels must be a list or a mapping")
+	}
+
+	l.list, l.values = list, LabelValues(list)
+	return nil
+}
+
 func (s ManifestEntrySource) String() string {
 	return fmt.Sprintf("%s: %s", s.Type, s.Config)
 }
 
-func (s *ManifestEntrySource) UnmarshalYAML(data []byte) error {
+func (s *ManifestEntrySource) UnmarshalYAML(ctx context.Context, data []byte) error {
+	data, templated, err := applySourceTemplate(ctx, data)
+	if err != nil {
+		return err
+	}
+
 	t := struct {
-		Type   string   `yaml:"type"`
-		Labels []string `yaml:"labels"`
+		Type      string          `yaml:"type"`
+		Labels    labels          `yaml:"labels"`
+		Policy    *SourcePolicy   `yaml:"policy"`
+		Transform []TransformStep `yaml:"transform"`
+		When      *Condition      `yaml:"when"`
 	}{}
 	if err := yaml.Unmarshal(data, &t); err != nil {
 		return fmt.Errorf("unmarshaling type: %w", err)
 	}
 
-	p, ok := sources.Get(t.Type)
+	p, ok := sourceLookupFromContext(ctx)(t.Type)
 	if !ok {
 		return fmt.Errorf("unknown source: %s", t.Type)
 	}
@@ -40,18 +194,28 @@ func (s *ManifestEntrySource) UnmarshalYAML(data []byte) error {
 		return fmt.Errorf("unmarshaling source raw configuration: %w", err)
 	}
 
-	pcfg, ok := cfg[t.Type]
-	if !ok {
-		return fmt.Errorf("missing source configuration")
-	}
-
+	// sources declared without configuration get the one derived from the name of
+	// the secret, see NamingRules
 	tCfg := p.ConfigFactory()
-	if err := yaml.Unmarshal([]byte(pcfg), tCfg); err != nil {
-		return fmt.Errorf("unmarshaling source typed configuration: %w", err)
+	if pcfg, ok := cfg[t.Type]; ok {
+		// the configuration of templated sources is merged from several places so
+		// misspelled fields are rejected instead of silently dropped
+		var opts []yaml.DecodeOption
+		if templated {
+			opts = append(opts, yaml.DisallowUnknownField())
+		}
+
+		if err := yaml.UnmarshalWithOptions([]byte(pcfg), tCfg, opts...); err != nil {
+			return fmt.Errorf("unmarshaling source typed configuration: %w", err)
+		}
 	}
 
 	s.Type = t.Type
-	s.Labels = t.Labels
+	s.Labels = t.Labels.list
+	s.LabelValues = t.Labels.values
+	s.Policy = t.Policy
+	s.Transform = t.Transform
+	s.When = t.When
 	s.Config = tCfg
 
 	return nil
@@ -61,32 +225,202 @@ type ManifestEntry struct {
 	Name        string                `yaml:"name"`
 	Description string                `yaml:"description"`
 	Sources     []ManifestEntrySource `yaml:"sources"`
+	Cache       *CacheConfig          `yaml:"cache"`
+	// Deadline caps the time spent resolving the secret across all its sources.
+	Deadline time.Duration `yaml:"deadline"`
+	// Required defaults to true when not set.
+	Required *bool `yaml:"required"`
+	// Default is the value used when none of the sources returns one.
+	Default *string `yaml:"default"`
+	// Validate declares the rules the values returned by the sources must satisfy.
+	Validate *ValidationRules `yaml:"validate"`
+	// Derived declares the value of the secret as a template over other secrets
+	// instead of sources.
+	Derived *DerivedConfig `yaml:"derived"`
+	// Aliases are former names of the secret that keep resolving to it.
+	Aliases    []string     `yaml:"aliases"`
+	Deprecated *Deprecation `yaml:"deprecated"`
 }
 
-// parseManifest parses the YAML manifest and returns a slice of manifestEntry.
+// Deprecation flags a secret that is going away.
+type Deprecation struct {
+	// Since is the version or date the secret was deprecated in.
+	Since string `yaml:"since"`
+	// Replacement is the name of the secret to use instead.
+	Replacement string `yaml:"replacement"`
+	Message     string `yaml:"message"`
+}
+
+// DerivedConfig configures a secret composed from other secrets.
+type DerivedConfig struct {
+	// Template referencing other secrets, e.g. `{{ secret "db_user" }}`.
+	Template string `yaml:"template"`
+}
+
+const (
+	// OnFailureNotFound treats a source returning an invalid value as if it returned none.
+	OnFailureNotFound = "not_found"
+	// OnFailureError treats a source returning an invalid value as failed.
+	OnFailureError = "error"
+)
+
+// ValidationRules are checked against the values returned by the sources.
+type ValidationRules struct {
+	Regex     string `yaml:"regex"`
+	MinLength int    `yaml:"min_length"`
+	MaxLength int    `yaml:"max_length"`
+	// Charset is one of alphanumeric, hex, base64, base64url, ascii or printable.
+	Charset string `yaml:"charset"`
+	// Format is one of url, uuid, base64, jwt, pem_certificate, pem_private_key or json.
+	Format string `yaml:"format"`
+	// OnFailure is either not_found, the default, or error.
+	OnFailure string `yaml:"on_failure"`
+}
+
+// IsRequired returns whether the secret must be available.
+func (e ManifestEntry) IsRequired() bool {
+	return e.Required == nil || *e.Required
+}
+
+// CacheConfig configures the caching of a resolved secret.
+type CacheConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// SourceLookup returns the secret source registered under the given type.
+type SourceLookup func(typ string) (types.SecretSource, bool)
+
+type sourceLookupKey struct{}
+
+func sourceLookupFromContext(ctx context.Context) SourceLookup {
+	if l, ok := ctx.Value(sourceLookupKey{}).(SourceLookup); ok && l != nil {
+		return l
+	}
+
+	return sources.Get
+}
+
+// Options for parsing a manifest.
+type Options struct {
+	// Variables used to render the manifest template.
+	Variables map[string]string
+	// Sources resolves the source types declared in the manifest. Defaults to
+	// the globally registered sources.
+	Sources SourceLookup
+	// Overlays are applied in order on top of the manifest, see Overlay.
+	Overlays [][]byte
+}
+
+// ParseManifest parses the YAML manifest and returns a slice of manifestEntry.
 // If the manifest contains variables, it will render them using the provided LoadOptions.
 // It returns an error if the manifest cannot be parsed or rendered.
 func ParseManifest(manifest []byte, vars map[string]string) ([]ManifestEntry, error) {
-	var cfg []ManifestEntry
+	return ParseManifestWithOptions(manifest, Options{Variables: vars})
+}
+
+// ParseManifestWithOptions is like ParseManifest but allows to customize how the
+// manifest is rendered and how its sources are resolved.
+func ParseManifestWithOptions(manifest []byte, opts Options) ([]ManifestEntry, error) {
+	m, err := Parse(manifest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Secrets, nil
+}
+
+// Manifest is a parsed manifest document. Manifests are either a list of secrets
+// or a mapping with the secrets under the `secrets` key along with other sections.
+type Manifest struct {
+	// Profiles selects the sources to use per environment, by name.
+	Profiles map[string]Profile `yaml:"profiles"`
+	// Naming derives the configuration of the sources declared without one.
+	Naming  NamingRules     `yaml:"naming"`
+	Secrets []ManifestEntry `yaml:"secrets"`
+}
+
+// manifestKeys are the sections of a manifest in its mapping form.
+var manifestKeys = []string{"naming", "profiles", "secrets", "source_templates"}
+
+// Profile selects the sources labelled with any of its labels.
+type Profile struct {
+	Labels []string `yaml:"labels"`
+}
+
+// Parse parses the YAML manifest in any of its forms.
+func Parse(manifest []byte, opts Options) (Manifest, error) {
+	var m Manifest
+
+	rConfig, err := render(manifest, opts.Variables)
+	if err != nil {
+		return m, err
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(rConfig, &doc); err != nil {
+		return m, fmt.Errorf("unmarshaling manifest: %w", err)
+	}
+
+	ctx := context.WithValue(context.Background(), sourceLookupKey{}, opts.Sources)
+
+	var target any = &m
+	switch doc := doc.(type) {
+	case []any:
+		target = &m.Secrets
+	case map[string]any:
+		for key := range doc {
+			if !slices.Contains(manifestKeys, key) {
+				return m, fmt.Errorf("unknown manifest section: %s", key)
+			}
+		}
 
-	rConfig := manifest
-	if len(vars) > 0 {
-		tmpl, err := template.New("manifest").Parse(string(manifest))
+		templates, err := parseSourceTemplates(doc["source_templates"])
 		if err != nil {
-			return nil, fmt.Errorf("parsing manifest: %w", err)
+			return m, err
 		}
 
-		s := bytes.Buffer{}
-		if err = tmpl.Execute(&s, vars); err != nil {
-			return nil, fmt.Errorf("rendering manifest: %w", err)
+		ctx = context.WithValue(ctx, sourceTemplatesKey{}, templates)
+	}
+
+	if err := yaml.UnmarshalContext(ctx, rConfig, target); err != nil {
+		return Manifest{}, fmt.Errorf("unmarshaling manifest: %w", err)
+	}
+
+	for name, p := range m.Profiles {
+		if len(p.Labels) == 0 {
+			return Manifest{}, fmt.Errorf("profile %q must select at least one label", name)
+		}
+	}
+
+	for i, overlay := range opts.Overlays {
+		if err := applyOverlay(ctx, m.Secrets, overlay, opts.Variables); err != nil {
+			return Manifest{}, fmt.Errorf("overlay #%d: %w", i, err)
 		}
+	}
+
+	return m, nil
+}
+
+// render renders the manifest template with the given variables, if any.
+func render(manifest []byte, vars map[string]string) ([]byte, error) {
+	if len(vars) == 0 {
+		return manifest, nil
+	}
 
-		rConfig = s.Bytes()
+	tmpl, err := template.New("manifest").Funcs(template.FuncMap{
+		// derived secrets are rendered at resolution time
+		"secret": func(name string) template.HTML {
+			return template.HTML(fmt.Sprintf("{{ secret %q }}", name))
+		},
+	}).Parse(string(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
 	}
 
-	if err := yaml.UnmarshalWithOptions(rConfig, &cfg); err != nil {
-		return nil, fmt.Errorf("unmarshaling manifest: %w", err)
+	s := bytes.Buffer{}
+	if err = tmpl.Execute(&s, vars); err != nil {
+		return nil, fmt.Errorf("rendering manifest: %w", err)
 	}
 
-	return cfg, nil
+	return s.Bytes(), nil
 }
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"testing"
+	"time"
 
+	"github.com/jcchavezs/pakay/internal/sources"
 	"github.com/jcchavezs/pakay/internal/sources/env"
 	onepasswordcli "github.com/jcchavezs/pakay/internal/sources/onepassword/cli"
 	"github.com/jcchavezs/pakay/internal/sources/stdin"
@@ -17,6 +19,15 @@ var successManifest = `---
     stdin: 
       prompt: Please insert the JIRA account's email
   - type: env
+    policy:
+      timeout: 2s
+      max_attempts: 3
+      backoff:
+        initial: 100ms
+        multiplier: 2
+    transform:
+    - base64_decode
+    - json_path: $.data.email
     env: 
       key: JIRA_EMAIL
   - type: 1password
@@ -36,6 +47,315 @@ func TestParseManifest(t *testing.T) {
 	require.Equal(t, "Please insert the JIRA account's email", m[0].Sources[0].Config.(*stdin.Config).Prompt)
 	require.Equal(t, "env", m[0].Sources[1].Type)
 	require.Equal(t, "JIRA_EMAIL", m[0].Sources[1].Config.(*env.Config).Key)
+	require.Equal(t, &SourcePolicy{
+		Timeout:     2 * time.Second,
+		MaxAttempts: 3,
+		Backoff:     BackoffPolicy{Initial: 100 * time.Millisecond, Multiplier: 2},
+	}, m[0].Sources[1].Policy)
+	require.Nil(t, m[0].Sources[0].Policy)
+	require.Equal(t, []TransformStep{
+		{Name: TransformBase64Decode},
+		{Name: TransformJSONPath, Arg: "$.data.email"},
+	}, m[0].Sources[1].Transform)
+	require.Nil(t, m[0].Sources[0].Transform)
 	require.Equal(t, "1password", m[0].Sources[2].Type)
 	require.Equal(t, "op://{{ $.op_vault }}/jira_email/username", m[0].Sources[2].Config.(*onepasswordcli.Config).Ref)
 }
+
+func TestParseManifestDocument(t *testing.T) {
+	m, err := Parse([]byte(`---
+profiles:
+  local:
+    labels: [stdin]
+  ci:
+    labels: [env]
+secrets:
+- name: jira_email
+  sources:
+  - type: stdin
+    labels: [stdin]
+    stdin:
+      prompt: Please insert the JIRA account's email
+  - type: env
+    labels: [env]
+    env:
+      key: JIRA_EMAIL
+`), Options{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]Profile{
+		"local": {Labels: []string{"stdin"}},
+		"ci":    {Labels: []string{"env"}},
+	}, m.Profiles)
+	require.Len(t, m.Secrets, 1)
+	require.Len(t, m.Secrets[0].Sources, 2)
+
+	// the list form has no profiles
+	m, err = Parse([]byte(successManifest), Options{})
+	require.NoError(t, err)
+	require.Nil(t, m.Profiles)
+	require.Len(t, m.Secrets, 1)
+
+	_, err = Parse([]byte("secret:\n- name: jira_email\n"), Options{})
+	require.ErrorContains(t, err, "unknown manifest section: secret")
+
+	_, err = Parse([]byte("profiles:\n  ci: {}\n"), Options{})
+	require.ErrorContains(t, err, `profile "ci" must select at least one label`)
+}
+
+func TestParseManifestLabels(t *testing.T) {
+	m, err := ParseManifest([]byte(`---
+- name: jira_email
+  sources:
+  - type: env
+    labels: [ci, env=ci, region=eu]
+    env:
+      key: JIRA_EMAIL
+  - type: env
+    labels:
+      env: prod
+    env:
+      key: JIRA_EMAIL_PROD
+`), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci", "env=ci", "region=eu"}, m[0].Sources[0].Labels)
+	require.Equal(t, map[string]string{"env": "ci", "region": "eu"}, m[0].Sources[0].LabelValues)
+	require.Nil(t, m[0].Sources[1].Labels)
+	require.Equal(t, map[string]string{"env": "prod"}, m[0].Sources[1].LabelValues)
+
+	_, err = ParseManifest([]byte("- name: jira_email\n  sources:\n  - type: env\n    labels: ci\n    env:\n      key: JIRA_EMAIL\n"), nil)
+	require.ErrorContains(t, err, "labels must be a list or a mapping")
+}
+
+func TestParseManifestOverlays(t *testing.T) {
+	base := `---
+- name: jira_email
+  sources:
+  - type: stdin
+    stdin:
+      prompt: Please insert the JIRA account's email
+  - type: env
+    env:
+      key: JIRA_EMAIL
+  - type: 1password
+    1password:
+      ref: op://{{ $.op_vault }}/jira_email/username
+- name: jira_token
+  sources:
+  - type: env
+    env:
+      key: JIRA_TOKEN
+`
+
+	m, err := ParseManifestWithOptions([]byte(base), Options{
+		Variables: map[string]string{"op_vault": "local"},
+		Overlays: [][]byte{
+			[]byte(`---
+- name: jira_email
+  sources:
+  - type: env
+    labels: [ci]
+    env:
+      key: CI_JIRA_EMAIL
+  - type: stdin
+    $patch: delete
+  order: [1password]
+`),
+			[]byte(`---
+- name: jira_token
+  sources:
+  - type: 1password
+    1password:
+      ref: op://{{ $.op_vault }}/jira_token/password
+`),
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, m[0].Sources, 2)
+	require.Equal(t, "1password", m[0].Sources[0].Type)
+	require.Equal(t, "op://local/jira_email/username", m[0].Sources[0].Config.(*onepasswordcli.Config).Ref)
+	require.Equal(t, "env", m[0].Sources[1].Type)
+	require.Equal(t, []string{"ci"}, m[0].Sources[1].Labels)
+	require.Equal(t, "CI_JIRA_EMAIL", m[0].Sources[1].Config.(*env.Config).Key)
+
+	require.Len(t, m[1].Sources, 2)
+	require.Equal(t, "JIRA_TOKEN", m[1].Sources[0].Config.(*env.Config).Key)
+	require.Equal(t, "op://local/jira_token/password", m[1].Sources[1].Config.(*onepasswordcli.Config).Ref)
+
+	for overlay, expected := range map[string]string{
+		"- name: jira_password\n  sources: []\n":                                         `overlay #0: unknown secret "jira_password"`,
+		"- name: jira_token\n  sources:\n  - type: stdin\n    $patch: delete\n":          `overlay #0: secret "jira_token": no stdin source to delete`,
+		"- name: jira_token\n  order: [1password]\n":                                     `overlay #0: secret "jira_token": no 1password source to order`,
+		"- name: jira_token\n  sources:\n  - type: env\n    $patch: merge\n":             "unknown patch: merge",
+		"- name: jira_token\n  description: The JIRA token\n":                            "unknown field",
+		"- name: jira_token\n  sources:\n  - type: env\n    env:\n      key: JIRA_PAT\n": "",
+	} {
+		_, err := ParseManifestWithOptions([]byte(base), Options{Overlays: [][]byte{[]byte(overlay)}})
+		if expected == "" {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, expected)
+		}
+	}
+
+	_, err = ParseManifestWithOptions([]byte(`---
+- name: jira_token
+  sources:
+  - type: env
+    env:
+      key: JIRA_TOKEN
+  - type: env
+    env:
+      key: JIRA_PAT
+`), Options{Overlays: [][]byte{[]byte("- name: jira_token\n  order: [env]\n")}})
+	require.ErrorContains(t, err, "several env sources")
+}
+
+func TestParseManifestSourceTemplates(t *testing.T) {
+	m, err := Parse([]byte(`---
+source_templates:
+  team_vault:
+    type: 1password
+    labels: [local, team=payments]
+    policy:
+      timeout: 5s
+    1password:
+      ref: op://team/default/password
+  ci_env: &ci_env
+    type: env
+    labels: [ci]
+secrets:
+- name: api_token
+  sources:
+  - use: ci_env
+    env:
+      key: API_TOKEN
+  - use: team_vault
+    1password:
+      ref: op://team/api/token
+- name: db_password
+  sources:
+  - use: team_vault
+    labels: [local]
+  - <<: *ci_env
+    env:
+      key: DB_PASSWORD
+`), Options{})
+	require.NoError(t, err)
+
+	token := m.Secrets[0].Sources
+	require.Equal(t, "env", token[0].Type)
+	require.Equal(t, []string{"ci"}, token[0].Labels)
+	require.Equal(t, "API_TOKEN", token[0].Config.(*env.Config).Key)
+	require.Equal(t, "1password", token[1].Type)
+	require.Equal(t, []string{"local", "team=payments"}, token[1].Labels)
+	require.Equal(t, map[string]string{"team": "payments"}, token[1].LabelValues)
+	require.Equal(t, &SourcePolicy{Timeout: 5 * time.Second}, token[1].Policy)
+	require.Equal(t, "op://team/api/token", token[1].Config.(*onepasswordcli.Config).Ref)
+
+	password := m.Secrets[1].Sources
+	require.Equal(t, []string{"local"}, password[0].Labels)
+	require.Nil(t, password[0].LabelValues)
+	require.Equal(t, "op://team/default/password", password[0].Config.(*onepasswordcli.Config).Ref)
+	require.Equal(t, "env", password[1].Type)
+	require.Equal(t, "DB_PASSWORD", password[1].Config.(*env.Config).Key)
+
+	for manifest, expected := range map[string]string{
+		"secrets:\n- name: api_token\n  sources:\n  - use: team_vault\n":                                                 "unknown source template: team_vault",
+		"- name: api_token\n  sources:\n  - use: team_vault\n":                                                           "unknown source template: team_vault",
+		"source_templates:\n  a: env\n":                                                                                  `source template "a" must be a mapping`,
+		"source_templates:\n  a:\n    use: b\n  b:\n    type: env\n":                                                     `source template "a" cannot use another template`,
+		"source_templates:\n  a:\n    type: env\n    lables: [ci]\nsecrets:\n- name: s\n  sources:\n  - use: a\n":        `source template "a": unknown field "lables"`,
+		"source_templates:\n  a:\n    type: env\n    env:\n      kye: A\nsecrets:\n- name: s\n  sources:\n  - use: a\n":  `unknown field "kye"`,
+		"source_templates:\n  a:\n    type: env\n    vars: [a]\nsecrets:\n- name: s\n  sources:\n  - use: a\n":           "vars must be a mapping",
+		"source_templates:\n  a:\n    type: env\n    vars:\n      a: [b]\nsecrets:\n- name: s\n  sources:\n  - use: a\n": `var "a" must be a scalar`,
+		"- name: s\n  sources:\n  - type: env\n    vars:\n      a: b\n":                                                  "vars can only be declared by sources using a template",
+	} {
+		_, err := Parse([]byte(manifest), Options{})
+		require.ErrorContains(t, err, expected, manifest)
+	}
+}
+
+func TestParseManifestSourceTemplateVars(t *testing.T) {
+	m, err := Parse([]byte(`---
+source_templates:
+  team_vault:
+    type: 1password
+    vars:
+      vault: team
+      item: default
+    1password:
+      ref: op://${vault}/${item}/password
+secrets:
+- name: api_token
+  sources:
+  - use: team_vault
+    1password:
+      ref: op://${vault}/api/token
+- name: db_password
+  sources:
+  - use: team_vault
+    vars:
+      item: db
+  - use: team_vault
+    vars:
+      vault: {{ .vault }}
+      item: ${unknown}
+`), Options{Variables: map[string]string{"vault": "ops"}})
+	require.NoError(t, err)
+
+	require.Equal(t, "op://team/api/token", m.Secrets[0].Sources[0].Config.(*onepasswordcli.Config).Ref)
+	require.Equal(t, "op://team/db/password", m.Secrets[1].Sources[0].Config.(*onepasswordcli.Config).Ref)
+	require.Equal(t, "op://ops/${unknown}/password", m.Secrets[1].Sources[1].Config.(*onepasswordcli.Config).Ref)
+}
+
+func TestNamingRules(t *testing.T) {
+	m, err := Parse([]byte(`---
+naming:
+  1password:
+    case: kebab
+    config:
+      ref: op://team/{name}/password
+secrets:
+- name: jira_api.token
+  sources:
+  - type: env
+  - type: env
+    env:
+      key: JIRA_TOKEN
+  - type: 1password
+  - type: stdin
+    stdin:
+      prompt: Please insert the JIRA token
+`), Options{})
+	require.NoError(t, err)
+	require.Equal(t, NamingRules{
+		"1password": {Case: CaseKebab, Config: map[string]string{"ref": "op://team/{name}/password"}},
+	}, m.Naming)
+
+	rules := DefaultNaming.Merge(NamingRules{"env": {Prefix: "MYAPP_"}}).Merge(m.Naming)
+	require.Equal(t, NamingRule{Prefix: "MYAPP_", Case: CaseUpper, Config: map[string]string{"key": "{name}"}}, rules["env"])
+	require.Empty(t, DefaultNaming["env"].Prefix)
+
+	require.NoError(t, rules.Apply(m.Secrets, sources.Get))
+	ss := m.Secrets[0].Sources
+	require.Equal(t, "MYAPP_JIRA_API_TOKEN", ss[0].Config.(*env.Config).Key)
+	require.Equal(t, "JIRA_TOKEN", ss[1].Config.(*env.Config).Key)
+	require.Equal(t, "op://team/jira-api-token/password", ss[2].Config.(*onepasswordcli.Config).Ref)
+	require.Equal(t, "Please insert the JIRA token", ss[3].Config.(*stdin.Config).Prompt)
+
+	name, err := NamingRule{Case: CaseLower}.name("jira_api.token")
+	require.NoError(t, err)
+	require.Equal(t, "jira_api_token", name)
+
+	name, err = NamingRule{Prefix: "x."}.name("jira_api.token")
+	require.NoError(t, err)
+	require.Equal(t, "x.jira_api.token", name)
+
+	m, err = Parse([]byte("- name: jira_token\n  sources:\n  - type: 1password\n"), Options{})
+	require.NoError(t, err)
+	require.ErrorContains(t, DefaultNaming.Apply(m.Secrets, sources.Get), `1password source of "jira_token" has no configuration and there is no naming rule for it`)
+
+	rules = DefaultNaming.Merge(NamingRules{"1password": {Case: "camel", Config: map[string]string{"ref": "{name}"}}})
+	require.ErrorContains(t, rules.Apply(m.Secrets, sources.Get), "unknown case: camel")
+}
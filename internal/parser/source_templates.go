@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// SourceTemplates are partial sources declared under `source_templates` that sources
+// reference with `use` and override, e.g.
+//
+//	source_templates:
+//	  team_vault:
+//	    type: 1password
+//	    labels: [local]
+//	    vars:
+//	      vault: team
+//	    1password:
+//	      ref: op://${vault}/default/password
+//	secrets:
+//	- name: api_token
+//	  sources:
+//	  - use: team_vault
+//	    1password:
+//	      ref: op://${vault}/api/token
+//
+// Mappings are merged recursively, any other value declared by the source replaces
+// the one of the template. The `vars` of the template, overridden by the ones of the
+// source, replace their `${var}` placeholders in the string values of the resulting
+// source; placeholders of undeclared variables are left as they are.
+type SourceTemplates map[string]map[string]any
+
+type sourceTemplatesKey struct{}
+
+func sourceTemplatesFromContext(ctx context.Context) SourceTemplates {
+	t, _ := ctx.Value(sourceTemplatesKey{}).(SourceTemplates)
+	return t
+}
+
+// parseSourceTemplates parses the `source_templates` section of a manifest.
+func parseSourceTemplates(section any) (SourceTemplates, error) {
+	if section == nil {
+		return nil, nil
+	}
+
+	m, ok := section.(map[string]any)
+	if !ok {
+		return nil, errors.New("source_templates must be a mapping")
+	}
+
+	templates := make(SourceTemplates, len(m))
+	for name, t := range m {
+		tm, ok := t.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("source template %q must be a mapping", name)
+		}
+
+		if _, ok := tm["use"]; ok {
+			return nil, fmt.Errorf("source template %q cannot use another template", name)
+		}
+
+		templates[name] = tm
+	}
+
+	return templates, nil
+}
+
+// templateKeys are the fields a source template can declare besides the configuration
+// of its source type.
+var templateKeys = []string{"type", "labels", "policy", "transform", "when", "vars"}
+
+// applySourceTemplate returns the source merged over the template it uses, if any,
+// and whether it does.
+func applySourceTemplate(ctx context.Context, data []byte) ([]byte, bool, error) {
+	var src map[string]any
+	if err := yaml.Unmarshal(data, &src); err != nil {
+		return nil, false, fmt.Errorf("unmarshaling source: %w", err)
+	}
+
+	use, ok := src["use"]
+	if !ok {
+		if _, ok := src["vars"]; ok {
+			return nil, false, errors.New("vars can only be declared by sources using a template")
+		}
+
+		return data, false, nil
+	}
+
+	name, ok := use.(string)
+	if !ok || name == "" {
+		return nil, false, errors.New("use must be the name of a source template")
+	}
+
+	t, ok := sourceTemplatesFromContext(ctx)[name]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown source template: %s", name)
+	}
+
+	delete(src, "use")
+	merged := merge(t, src)
+
+	typ, _ := merged["type"].(string)
+	for k := range t {
+		if k != typ && !slices.Contains(templateKeys, k) {
+			return nil, false, fmt.Errorf("source template %q: unknown field %q", name, k)
+		}
+	}
+
+	vars, err := templateVars(merged["vars"])
+	if err != nil {
+		return nil, false, fmt.Errorf("source template %q: %w", name, err)
+	}
+	delete(merged, "vars")
+
+	out, err := yaml.Marshal(expandVars(merged, vars))
+	if err != nil {
+		return nil, false, fmt.Errorf("merging source template %q: %w", name, err)
+	}
+
+	return out, true, nil
+}
+
+// templateVars returns the `vars` of a source as the replacements of their
+// placeholders.
+func templateVars(v any) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("vars must be a mapping")
+	}
+
+	replacements := make([]string, 0, 2*len(m))
+	for k, val := range m {
+		switch val.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("var %q must be a scalar", k)
+		case nil:
+			val = ""
+		}
+
+		replacements = append(replacements, "${"+k+"}", fmt.Sprint(val))
+	}
+
+	return replacements, nil
+}
+
+// expandVars returns the value with the placeholders replaced in all its strings.
+func expandVars(v any, replacements []string) any {
+	if len(replacements) == 0 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case string:
+		return strings.NewReplacer(replacements...).Replace(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = expandVars(e, replacements)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = expandVars(e, replacements)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// merge returns the override merged recursively over the base, neither of which
+// is modified.
+func merge(base, override map[string]any) map[string]any {
+	out := maps.Clone(base)
+	for k, v := range override {
+		if bm, ok := out[k].(map[string]any); ok {
+			if om, ok := v.(map[string]any); ok {
+				out[k] = merge(bm, om)
+				continue
+			}
+		}
+
+		out[k] = v
+	}
+
+	return out
+}
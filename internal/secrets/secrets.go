@@ -1,23 +1,448 @@
 package secrets
 
 import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jcchavezs/pakay/internal/derive"
 	"github.com/jcchavezs/pakay/internal/parser"
 	"github.com/jcchavezs/pakay/types"
 )
 
 type (
 	Getter struct {
-		Labels []string
-		types.SecretGetter
+		Labels      []string
+		LabelValues map[string]string
+		Interactive bool
+		// Key identifies the source and its configuration so concurrent invocations
+		// of the same source, even from different secrets, can be deduplicated.
+		Key string
+		// Transform is applied to the values returned by the resolver, nil if the
+		// source declares no transformation.
+		Transform func(value string) (string, error)
+		// Condition returns why the source must be skipped, nil if the source
+		// declares no condition.
+		Condition func() error
+		types.SecretResolver
 	}
 
 	Secret struct {
 		parser.ManifestEntry
 		Getters []Getter
+		// Validator checks the values returned by the getters, nil if the secret
+		// declares no validation rules.
+		Validator func(value string) error
+		// Template renders the value of a derived secret, nil for the secrets
+		// resolved from sources.
+		Template *derive.Template
+	}
+
+	// Source describes a secret source when deciding whether it should take part
+	// in a lookup.
+	Source struct {
+		Type        string
+		Labels      []string
+		LabelValues map[string]string
+		// Interactive is set for the sources prompting the user, e.g. stdin.
+		Interactive bool
 	}
-)
 
-var (
-	All    = map[string]Secret{}
-	Loaded bool
+	// FilterIn sources that should be considered in the secret evaluation
+	FilterIn func(Source) bool
 )
+
+// State is an immutable set of loaded secrets. Readers get a consistent view of
+// the secrets for as long as they hold it, regardless of concurrent loads.
+type State struct {
+	secrets map[string]Secret
+	names   []string
+	// aliases maps the aliases to the names of the secrets declaring them.
+	aliases  map[string]string
+	profiles map[string]parser.Profile
+}
+
+// Profiles returns the names of the declared profiles, sorted.
+func (st *State) Profiles() []string {
+	if st == nil {
+		return nil
+	}
+
+	return slices.Sorted(maps.Keys(st.profiles))
+}
+
+// Profile returns a filter selecting the sources of the given profile, which are
+// the ones labelled with any of its labels. A key=value label of the profile
+// matches the sources whose label key holds that value.
+func (st *State) Profile(name string) (FilterIn, bool) {
+	if st == nil {
+		return nil, false
+	}
+
+	p, ok := st.profiles[name]
+	if !ok {
+		return nil, false
+	}
+
+	var (
+		plain  []string
+		values = map[string][]string{}
+	)
+	for _, l := range p.Labels {
+		if k, v, ok := strings.Cut(l, "="); ok {
+			values[k] = append(values[k], v)
+			continue
+		}
+
+		plain = append(plain, l)
+	}
+
+	return func(src Source) bool {
+		for _, l := range src.Labels {
+			if slices.Contains(plain, l) {
+				return true
+			}
+		}
+
+		for k, v := range src.LabelValues {
+			if slices.Contains(values[k], v) {
+				return true
+			}
+		}
+
+		return false
+	}, true
+}
+
+// addProfiles declares the profiles in a state being built.
+func (st *State) addProfiles(profiles map[string]parser.Profile) error {
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		if _, ok := st.profiles[name]; ok {
+			return fmt.Errorf("duplicated declaration for profile %q", name)
+		}
+
+		st.profiles[name] = profiles[name]
+	}
+
+	return nil
+}
+
+func newState() *State {
+	return &State{
+		secrets:  map[string]Secret{},
+		aliases:  map[string]string{},
+		profiles: map[string]parser.Profile{},
+	}
+}
+
+// Get returns the secret with the given name or alias.
+func (st *State) Get(name string) (Secret, bool) {
+	sec, ok := st.secrets[st.canonical(name)]
+	return sec, ok
+}
+
+// canonical returns the name of the secret declaring the given alias, or the given
+// name if it isn't an alias.
+func (st *State) canonical(name string) string {
+	if n, ok := st.aliases[name]; ok {
+		return n
+	}
+
+	return name
+}
+
+// add declares the secret in a state being built, ensuring neither its name nor
+// its aliases are already declared.
+func (st *State) add(sec Secret) error {
+	for _, name := range append([]string{sec.Name}, sec.Aliases...) {
+		if _, ok := st.secrets[name]; ok {
+			return fmt.Errorf("duplicated declaration for %q", name)
+		}
+
+		if _, ok := st.aliases[name]; ok {
+			return fmt.Errorf("duplicated declaration for %q", name)
+		}
+	}
+
+	for _, alias := range sec.Aliases {
+		if alias == sec.Name {
+			return fmt.Errorf("%q cannot be an alias of itself", alias)
+		}
+
+		st.aliases[alias] = sec.Name
+	}
+
+	st.secrets[sec.Name] = sec
+	st.names = append(st.names, sec.Name)
+	return nil
+}
+
+// All returns all the secrets in the order they were declared.
+func (st *State) All() []Secret {
+	ss := make([]Secret, 0, len(st.names))
+	for _, name := range st.names {
+		ss = append(ss, st.secrets[name])
+	}
+
+	return ss
+}
+
+// Source describes the i-th source of the secret.
+func (s Secret) Source(i int) Source {
+	return Source{
+		Type:        s.Sources[i].Type,
+		Labels:      s.Getters[i].Labels,
+		LabelValues: s.Getters[i].LabelValues,
+		Interactive: s.Getters[i].Interactive,
+	}
+}
+
+// Dependencies returns the names of the secrets a derived secret is composed from.
+func (s Secret) Dependencies() []string {
+	if s.Template == nil {
+		return nil
+	}
+
+	return s.Template.Dependencies
+}
+
+// checkDependencies ensures the dependencies of the derived secrets are declared
+// and don't form a cycle.
+func (st *State) checkDependencies() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	marks := make(map[string]int, len(st.names))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visiting:
+			cycle := append(path[slices.Index(path, name):], name)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		case visited:
+			return nil
+		}
+
+		marks[name] = visiting
+		for _, dep := range st.secrets[name].Dependencies() {
+			if _, ok := st.Get(dep); !ok {
+				return fmt.Errorf("%q depends on undeclared secret %q", name, dep)
+			}
+
+			if err := visit(st.canonical(dep), append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+
+		return nil
+	}
+
+	for _, name := range st.names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dependents returns the names of the secrets derived, directly or transitively,
+// from any of the given ones.
+func (st *State) Dependents(names ...string) []string {
+	if st == nil || len(names) == 0 {
+		return nil
+	}
+
+	affected := map[string]bool{}
+	for _, name := range names {
+		affected[name] = true
+	}
+
+	var dependents []string
+	// dependents are found in rounds as the manifest may declare them before the
+	// secrets they depend on.
+	for found := true; found; {
+		found = false
+		for _, name := range st.names {
+			if affected[name] {
+				continue
+			}
+
+			for _, dep := range st.secrets[name].Dependencies() {
+				if affected[st.canonical(dep)] {
+					affected[name] = true
+					dependents = append(dependents, name)
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	return dependents
+}
+
+// Store holds the secrets loaded into a registry. Loads are serialized and
+// published atomically so readers never observe a partially loaded manifest.
+type Store struct {
+	// FilterIn is the registry wide filter, applied on top of the filter passed
+	// to each lookup.
+	FilterIn FilterIn
+	// selector is the filter of the registry wide selector, if any.
+	selector atomic.Pointer[FilterIn]
+
+	writeMu sync.Mutex
+	state   atomic.Pointer[State]
+}
+
+// NewStore returns an empty store.
+func NewStore(filterIn FilterIn) *Store {
+	return &Store{FilterIn: filterIn}
+}
+
+// State returns the current state of the store or nil if nothing has been loaded yet.
+func (s *Store) State() *State {
+	return s.state.Load()
+}
+
+// Add publishes the given secrets and profiles on top of the ones already loaded
+// and returns the previous state. Either all of them are added or, if any name is
+// already declared, none.
+func (s *Store) Add(ss []Secret, profiles map[string]parser.Profile) (*State, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.publish(s.state.Load(), ss, profiles)
+}
+
+// Replace publishes the given secrets and profiles in place of the ones already
+// loaded and returns the previous state. On error the store is left untouched.
+func (s *Store) Replace(ss []Secret, profiles map[string]parser.Profile) (*State, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.publish(nil, ss, profiles)
+}
+
+// publish builds the state made of the given secrets and profiles on top of a copy
+// of base, which can be nil, validates it and swaps it in. Callers hold writeMu.
+func (s *Store) publish(base *State, ss []Secret, profiles map[string]parser.Profile) (*State, error) {
+	next := newState()
+	if base != nil {
+		maps.Copy(next.secrets, base.secrets)
+		maps.Copy(next.aliases, base.aliases)
+		maps.Copy(next.profiles, base.profiles)
+		next.names = slices.Clone(base.names)
+	}
+
+	if err := next.addProfiles(profiles); err != nil {
+		return nil, err
+	}
+
+	for _, sec := range ss {
+		if err := next.add(sec); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := next.checkDependencies(); err != nil {
+		return nil, err
+	}
+
+	return s.state.Swap(next), nil
+}
+
+// Reset unloads all the secrets and returns the previous state.
+func (s *Store) Reset() *State {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.state.Swap(nil)
+}
+
+// All returns all the loaded secrets in the order they were declared.
+func (s *Store) All() []Secret {
+	st := s.state.Load()
+	if st == nil {
+		return nil
+	}
+
+	return st.All()
+}
+
+// Diff returns the names of the secrets added, removed and changed between two
+// states. Either state can be nil.
+func Diff(prev, next *State) (added, removed, changed []string) {
+	if prev == nil {
+		prev = &State{}
+	}
+
+	if next == nil {
+		next = &State{}
+	}
+
+	for _, name := range next.names {
+		p, ok := prev.secrets[name]
+		if !ok {
+			added = append(added, name)
+		} else if !reflect.DeepEqual(p.ManifestEntry, next.secrets[name].ManifestEntry) {
+			changed = append(changed, name)
+		}
+	}
+
+	for _, name := range prev.names {
+		if _, ok := next.secrets[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	return added, removed, changed
+}
+
+// SameProfiles returns whether both states declare the same profiles. Either state
+// can be nil.
+func SameProfiles(prev, next *State) bool {
+	if prev == nil || next == nil {
+		return len(prev.Profiles()) == 0 && len(next.Profiles()) == 0
+	}
+
+	return reflect.DeepEqual(prev.profiles, next.profiles)
+}
+
+// SetSelector sets the filter of the registry wide selector, nil to unset it.
+func (s *Store) SetSelector(selector FilterIn) {
+	s.selector.Store(&selector)
+}
+
+// Filter returns a filter combining the store filter and selector with the given one.
+func (s *Store) Filter(filterIn FilterIn) FilterIn {
+	var selector FilterIn
+	if p := s.selector.Load(); p != nil {
+		selector = *p
+	}
+
+	return And(And(s.FilterIn, selector), filterIn)
+}
+
+// And returns a filter selecting the sources selected by both filters. Either
+// filter can be nil.
+func And(a, b FilterIn) FilterIn {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+
+	return func(src Source) bool {
+		return a(src) && b(src)
+	}
+}
@@ -0,0 +1,205 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/pakay/internal/derive"
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func newSecret(name string) Secret {
+	return Secret{ManifestEntry: parser.ManifestEntry{Name: name}}
+}
+
+func TestStore(t *testing.T) {
+	t.Run("empty store has no state", func(t *testing.T) {
+		s := NewStore(nil)
+		require.Nil(t, s.State())
+		require.Empty(t, s.All())
+	})
+
+	t.Run("keeps declaration order", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Add([]Secret{newSecret("c"), newSecret("a")}, nil)
+		require.NoError(t, err)
+		_, err = s.Add([]Secret{newSecret("b")}, nil)
+		require.NoError(t, err)
+
+		names := []string{}
+		for _, sec := range s.All() {
+			names = append(names, sec.Name)
+		}
+		require.Equal(t, []string{"c", "a", "b"}, names)
+	})
+
+	t.Run("failed add leaves state untouched", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Add([]Secret{newSecret("a")}, nil)
+		require.NoError(t, err)
+		prev := s.State()
+
+		_, err = s.Add([]Secret{newSecret("b"), newSecret("a")}, nil)
+		require.ErrorContains(t, err, `duplicated declaration for "a"`)
+		require.Same(t, prev, s.State())
+
+		_, ok := s.State().Get("b")
+		require.False(t, ok)
+	})
+
+	t.Run("states are immutable", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Add([]Secret{newSecret("a")}, nil)
+		require.NoError(t, err)
+		prev := s.State()
+
+		_, err = s.Add([]Secret{newSecret("b")}, nil)
+		require.NoError(t, err)
+		_, ok := prev.Get("b")
+		require.False(t, ok)
+		require.Len(t, prev.All(), 1)
+		require.Len(t, s.State().All(), 2)
+	})
+}
+
+func TestStoreReplace(t *testing.T) {
+	s := NewStore(nil)
+	_, err := s.Add([]Secret{newSecret("a"), newSecret("b")}, nil)
+	require.NoError(t, err)
+	first := s.State()
+
+	prev, err := s.Replace([]Secret{newSecret("b"), newSecret("c")}, nil)
+	require.NoError(t, err)
+	require.Same(t, first, prev)
+	require.Len(t, s.State().All(), 2)
+
+	_, err = s.Replace([]Secret{newSecret("d"), newSecret("d")}, nil)
+	require.ErrorContains(t, err, `duplicated declaration for "d"`)
+	_, ok := s.State().Get("c")
+	require.True(t, ok)
+
+	prev = s.Reset()
+	require.Len(t, prev.All(), 2)
+	require.Nil(t, s.State())
+}
+
+func TestDiff(t *testing.T) {
+	changedB := newSecret("b")
+	changedB.Description = "changed"
+
+	prev := &State{
+		secrets: map[string]Secret{"a": newSecret("a"), "b": newSecret("b"), "c": newSecret("c")},
+		names:   []string{"a", "b", "c"},
+	}
+	next := &State{
+		secrets: map[string]Secret{"b": changedB, "c": newSecret("c"), "d": newSecret("d")},
+		names:   []string{"b", "c", "d"},
+	}
+
+	added, removed, changed := Diff(prev, next)
+	require.Equal(t, []string{"d"}, added)
+	require.Equal(t, []string{"a"}, removed)
+	require.Equal(t, []string{"b"}, changed)
+
+	added, removed, changed = Diff(nil, next)
+	require.Equal(t, []string{"b", "c", "d"}, added)
+	require.Empty(t, removed)
+	require.Empty(t, changed)
+
+	added, removed, _ = Diff(prev, nil)
+	require.Empty(t, added)
+	require.Equal(t, []string{"a", "b", "c"}, removed)
+}
+
+func TestStoreFilter(t *testing.T) {
+	onlyEnv := func(s Source) bool { return s.Type == "env" }
+	onlyCI := func(s Source) bool { return len(s.Labels) > 0 && s.Labels[0] == "ci" }
+
+	require.Nil(t, NewStore(nil).Filter(nil))
+
+	f := NewStore(onlyEnv).Filter(nil)
+	require.True(t, f(Source{Type: "env"}))
+	require.False(t, f(Source{Type: "static"}))
+
+	f = NewStore(onlyEnv).Filter(onlyCI)
+	require.True(t, f(Source{Type: "env", Labels: []string{"ci"}}))
+	require.False(t, f(Source{Type: "env"}))
+	require.False(t, f(Source{Type: "static", Labels: []string{"ci"}}))
+}
+
+func newDerivedSecret(t *testing.T, name, tmpl string) Secret {
+	d, err := derive.Parse(name, tmpl)
+	require.NoError(t, err)
+
+	s := newSecret(name)
+	s.Template = d
+	return s
+}
+
+func TestStoreDependencies(t *testing.T) {
+	t.Run("rejects undeclared dependencies", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Add([]Secret{newDerivedSecret(t, "dsn", `{{ secret "user" }}`)}, nil)
+		require.EqualError(t, err, `"dsn" depends on undeclared secret "user"`)
+		require.Nil(t, s.State())
+	})
+
+	t.Run("rejects cycles", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Replace([]Secret{
+			newDerivedSecret(t, "a", `{{ secret "b" }}`),
+			newDerivedSecret(t, "b", `{{ secret "c" }}`),
+			newDerivedSecret(t, "c", `{{ secret "a" }}`),
+		}, nil)
+		require.EqualError(t, err, "dependency cycle: a -> b -> c -> a")
+	})
+
+	t.Run("accepts dependencies across loads", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Add([]Secret{newSecret("user")}, nil)
+		require.NoError(t, err)
+		_, err = s.Add([]Secret{newDerivedSecret(t, "dsn", `{{ secret "user" }}`)}, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("finds transitive dependents", func(t *testing.T) {
+		s := NewStore(nil)
+		_, err := s.Add([]Secret{
+			newDerivedSecret(t, "url", `https://{{ secret "dsn" }}`),
+			newDerivedSecret(t, "dsn", `{{ secret "user" }}@{{ secret "host" }}`),
+			newSecret("user"),
+			newSecret("host"),
+			newSecret("other"),
+		}, nil)
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []string{"dsn", "url"}, s.State().Dependents("user"))
+		require.Empty(t, s.State().Dependents("other"))
+		require.Empty(t, s.State().Dependents())
+	})
+}
+
+func TestStoreAliases(t *testing.T) {
+	withAliases := func(name string, aliases ...string) Secret {
+		s := newSecret(name)
+		s.Aliases = aliases
+		return s
+	}
+
+	s := NewStore(nil)
+	_, err := s.Add([]Secret{withAliases("a", "old_a"), newSecret("b")}, nil)
+	require.NoError(t, err)
+
+	sec, ok := s.State().Get("old_a")
+	require.True(t, ok)
+	require.Equal(t, "a", sec.Name)
+
+	_, err = s.Add([]Secret{withAliases("c", "b")}, nil)
+	require.EqualError(t, err, `duplicated declaration for "b"`)
+
+	_, err = s.Add([]Secret{newSecret("old_a")}, nil)
+	require.EqualError(t, err, `duplicated declaration for "old_a"`)
+
+	_, err = s.Replace([]Secret{withAliases("a", "a")}, nil)
+	require.EqualError(t, err, `"a" cannot be an alias of itself`)
+}
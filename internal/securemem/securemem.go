@@ -0,0 +1,72 @@
+// Package securemem holds secret values in memory that is kept out of swap and core
+// dumps where the platform allows it, and that is wiped when no longer needed.
+package securemem
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrDestroyed is returned when reading a buffer that has been destroyed.
+var ErrDestroyed = errors.New("buffer has been destroyed")
+
+// Buffer holds a secret value. It is safe for concurrent use.
+type Buffer struct {
+	mu        sync.RWMutex
+	data      []byte
+	release   func() error
+	destroyed bool
+}
+
+// New copies the value into a new buffer. The caller is responsible for wiping
+// its own copy of the value. Buffers that become unreachable without being
+// destroyed are destroyed by the garbage collector, but as that may take a while
+// and memory is scarce, callers should destroy them as soon as they are done.
+func New(value []byte) (*Buffer, error) {
+	data, release, err := alloc(len(value))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(data, value)
+	b := &Buffer{data: data, release: release}
+	runtime.SetFinalizer(b, (*Buffer).Destroy)
+	return b, nil
+}
+
+// Copy returns a copy of the value held by the buffer.
+func (b *Buffer) Copy() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	out := make([]byte, len(b.data))
+	copy(out, b.data)
+	return out, nil
+}
+
+// Destroy wipes the value and releases the memory holding it. It is safe to
+// call it more than once.
+func (b *Buffer) Destroy() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.destroyed {
+		return nil
+	}
+
+	runtime.SetFinalizer(b, nil)
+	Wipe(b.data)
+	b.destroyed = true
+	b.data = nil
+	return b.release()
+}
+
+// Wipe overwrites the given bytes with zeros.
+func Wipe(b []byte) {
+	clear(b)
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package securemem
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc maps a region surrounded by inaccessible guard pages, locks it into RAM
+// and excludes it from core dumps. The returned slice ends right before the
+// trailing guard page so overflows fault instead of reading adjacent memory.
+func alloc(size int) ([]byte, func() error, error) {
+	pageSize := os.Getpagesize()
+	dataSize := (max(size, 1) + pageSize - 1) / pageSize * pageSize
+	total := dataSize + 2*pageSize
+
+	mem, err := unix.Mmap(-1, 0, total, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mapping memory: %w", err)
+	}
+
+	region := mem[pageSize : pageSize+dataSize]
+	release := func() error {
+		_ = unix.Munlock(region)
+		return unix.Munmap(mem)
+	}
+
+	if err := unix.Mprotect(mem[:pageSize], unix.PROT_NONE); err != nil {
+		_ = release()
+		return nil, nil, fmt.Errorf("protecting guard page: %w", err)
+	}
+
+	if err := unix.Mprotect(mem[pageSize+dataSize:], unix.PROT_NONE); err != nil {
+		_ = release()
+		return nil, nil, fmt.Errorf("protecting guard page: %w", err)
+	}
+
+	if err := unix.Mlock(region); err != nil {
+		_ = release()
+		return nil, nil, fmt.Errorf("locking memory: %w", err)
+	}
+
+	if err := unix.Madvise(region, unix.MADV_DONTDUMP); err != nil {
+		_ = release()
+		return nil, nil, fmt.Errorf("excluding memory from core dumps: %w", err)
+	}
+
+	return region[dataSize-size:], release, nil
+}
+
+// DisableCoreDumps marks the process as non-dumpable so its memory isn't written
+// to core dumps and can't be read by other processes of the same user via ptrace.
+func DisableCoreDumps() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package securemem
+
+// alloc falls back to the heap on platforms without support for locked memory.
+// The value is still wiped on destroy.
+func alloc(size int) ([]byte, func() error, error) {
+	return make([]byte, size), func() error { return nil }, nil
+}
+
+// DisableCoreDumps is a no-op on platforms other than Linux.
+func DisableCoreDumps() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package securemem
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	value := []byte("s3cr3t")
+	b, err := New(value)
+	require.NoError(t, err)
+
+	// the buffer holds its own copy
+	Wipe(value)
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0}, value)
+
+	out, err := b.Copy()
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", string(out))
+
+	require.NoError(t, b.Destroy())
+	require.NoError(t, b.Destroy())
+
+	_, err = b.Copy()
+	require.ErrorIs(t, err, ErrDestroyed)
+}
+
+func TestBufferSizes(t *testing.T) {
+	for _, size := range []int{0, 1, 4095, 4096, 4097, 10000} {
+		value := make([]byte, size)
+		for i := range value {
+			value[i] = byte(i)
+		}
+
+		b, err := New(value)
+		require.NoError(t, err)
+
+		out, err := b.Copy()
+		require.NoError(t, err)
+		require.Equal(t, value, out)
+		require.NoError(t, b.Destroy())
+	}
+}
+
+func TestBufferFinalizer(t *testing.T) {
+	released := make(chan struct{})
+	func() {
+		b, err := New([]byte("s3cr3t"))
+		require.NoError(t, err)
+
+		release := b.release
+		b.release = func() error {
+			close(released)
+			return release()
+		}
+	}()
+
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		select {
+		case <-released:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
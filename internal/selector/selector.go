@@ -0,0 +1,231 @@
+// Package selector parses label selectors, e.g. `env in (ci,prod),!interactive`,
+// into filters over the sources of the secrets.
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jcchavezs/pakay/internal/secrets"
+)
+
+const (
+	// KeyType matches the type of the source, e.g. `type!=static`.
+	KeyType = "type"
+	// KeyInteractive matches the interactive sources, e.g. `!interactive`.
+	KeyInteractive = "interactive"
+)
+
+var (
+	token = regexp.MustCompile(`^[A-Za-z0-9_./-]*$`)
+	set   = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+type requirement func(secrets.Source) bool
+
+// Parse parses a selector made of comma separated requirements, all of which must
+// hold for a source to be selected:
+//   - `key` and `!key` select the sources with or without the label.
+//   - `key=value`, `key==value` and `key!=value` compare the value of the label.
+//   - `key in (a,b)` and `key notin (a,b)` compare it against a set of values.
+//
+// Besides the labels, `type` is the type of the source and `interactive` is set for
+// the interactive sources. An empty selector returns a nil filter.
+func Parse(expr string) (secrets.FilterIn, error) {
+	parts, err := split(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]requirement, 0, len(parts))
+	for _, part := range parts {
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	return func(src secrets.Source) bool {
+		for _, req := range reqs {
+			if !req(src) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// split splits the selector into its requirements, leaving the commas of the sets
+// untouched.
+func split(expr string) ([]string, error) {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+
+	for i, c := range expr {
+		switch c {
+		case '(':
+			if depth++; depth > 1 {
+				return nil, errors.New("nested parentheses")
+			}
+		case ')':
+			if depth--; depth < 0 {
+				return nil, errors.New("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, errors.New("unbalanced parentheses")
+	}
+
+	parts = append(parts, expr[start:])
+	if len(parts) == 1 && strings.TrimSpace(parts[0]) == "" {
+		return nil, nil
+	}
+
+	for i, part := range parts {
+		if parts[i] = strings.TrimSpace(part); parts[i] == "" {
+			return nil, errors.New("empty requirement")
+		}
+	}
+
+	return parts, nil
+}
+
+func parseRequirement(expr string) (requirement, error) {
+	if m := set.FindStringSubmatch(expr); m != nil {
+		key, err := parseKey(m[1])
+		if err != nil {
+			return nil, err
+		}
+
+		var values []string
+		for _, v := range strings.Split(m[3], ",") {
+			v, err := parseValue(v)
+			if err != nil {
+				return nil, err
+			}
+
+			if v == "" {
+				return nil, errors.New("empty value in set")
+			}
+
+			values = append(values, v)
+		}
+
+		in := m[2] == "in"
+		return func(src secrets.Source) bool {
+			v, ok := value(src, key)
+			return (ok && slices.Contains(values, v)) == in
+		}, nil
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		k, v, ok := strings.Cut(expr, op)
+		if !ok {
+			continue
+		}
+
+		key, err := parseKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		want, err := parseValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		equal := op != "!="
+		return func(src secrets.Source) bool {
+			v, ok := value(src, key)
+			return (ok && v == want) == equal
+		}, nil
+	}
+
+	negated := strings.HasPrefix(expr, "!")
+	key, err := parseKey(strings.TrimPrefix(expr, "!"))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(src secrets.Source) bool {
+		return has(src, key) != negated
+	}, nil
+}
+
+func parseKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errors.New("missing key")
+	}
+
+	if !token.MatchString(s) {
+		return "", fmt.Errorf("invalid key %q", s)
+	}
+
+	return s, nil
+}
+
+func parseValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !token.MatchString(s) {
+		return "", fmt.Errorf("invalid value %q", s)
+	}
+
+	return s, nil
+}
+
+// value returns the value of the given key for the source.
+func value(src secrets.Source, key string) (string, bool) {
+	switch key {
+	case KeyType:
+		return src.Type, true
+	case KeyInteractive:
+		if src.Interactive {
+			return "true", true
+		}
+
+		return "false", true
+	}
+
+	v, ok := src.LabelValues[key]
+	return v, ok
+}
+
+// has returns whether the source has the given key, either as a key/value label
+// or as a plain one.
+func has(src secrets.Source, key string) bool {
+	switch key {
+	case KeyType:
+		return true
+	case KeyInteractive:
+		if src.Interactive {
+			return true
+		}
+	}
+
+	if _, ok := src.LabelValues[key]; ok {
+		return true
+	}
+
+	return slices.Contains(src.Labels, key)
+}
@@ -0,0 +1,67 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/pakay/internal/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	ci := secrets.Source{Type: "env", Labels: []string{"fast"}, LabelValues: map[string]string{"env": "ci"}}
+	prod := secrets.Source{Type: "1password", LabelValues: map[string]string{"env": "prod"}}
+	prompt := secrets.Source{Type: "stdin", Interactive: true}
+	static := secrets.Source{Type: "static"}
+	all := []secrets.Source{ci, prod, prompt, static}
+
+	for expr, expected := range map[string][]secrets.Source{
+		"env":               {ci, prod},
+		"!env":              {prompt, static},
+		"fast":              {ci},
+		"env=ci":            {ci},
+		"env==ci":           {ci},
+		"env!=ci":           {prod, prompt, static},
+		"env in (ci, prod)": {ci, prod},
+		"env notin (ci)":    {prod, prompt, static},
+		"type=stdin":        {prompt},
+		"type!=static":      {ci, prod, prompt},
+		"interactive":       {prompt},
+		"!interactive":      {ci, prod, static},
+		"interactive=false": {ci, prod, static},
+		"env in (ci,prod),!interactive,type!=static": {ci, prod},
+	} {
+		t.Run(expr, func(t *testing.T) {
+			f, err := Parse(expr)
+			require.NoError(t, err)
+
+			var selected []secrets.Source
+			for _, src := range all {
+				if f(src) {
+					selected = append(selected, src)
+				}
+			}
+
+			require.Equal(t, expected, selected)
+		})
+	}
+
+	f, err := Parse(" ")
+	require.NoError(t, err)
+	require.Nil(t, f)
+
+	for _, expr := range []string{
+		"env,",
+		"env in (ci",
+		"env in ((ci))",
+		"env)",
+		"=ci",
+		"!",
+		"env=c i",
+		"env in (ci,)",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			require.Error(t, err)
+		})
+	}
+}
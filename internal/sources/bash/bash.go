@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"time"
@@ -32,36 +33,50 @@ var Source = types.SecretSource{
 		return &Config{}
 	},
 	SecretGetterFactory: func(cfg types.SourceConfig) (types.SecretGetter, error) {
-		var (
-			command string
-			timeout time.Duration
-		)
-		if tCfg, ok := cfg.(*Config); ok {
-			command = tCfg.Command
-			timeout = time.Duration(tCfg.TimeoutMS) * time.Millisecond
-		} else {
-			return nil, errors.New("invalid config")
+		r, err := newSecretResolver(cfg)
+		if err != nil {
+			return nil, err
 		}
 
-		if command == "" {
-			return nil, errors.New("command cannot be empty")
+		return r.Getter(), nil
+	},
+	SecretResolverFactory: newSecretResolver,
+}
+
+func newSecretResolver(cfg types.SourceConfig) (types.SecretResolver, error) {
+	var (
+		command string
+		timeout time.Duration
+	)
+	if tCfg, ok := cfg.(*Config); ok {
+		command = tCfg.Command
+		timeout = time.Duration(tCfg.TimeoutMS) * time.Millisecond
+	} else {
+		return nil, errors.New("invalid config")
+	}
+
+	if command == "" {
+		return nil, errors.New("command cannot be empty")
+	}
+
+	return func(ctx context.Context) types.SecretResult {
+		if timeout > 0 {
+			var cancelFn context.CancelFunc
+			ctx, cancelFn = context.WithTimeout(ctx, timeout)
+			defer cancelFn()
 		}
 
-		return func(ctx context.Context) (string, bool) {
-			if timeout > 0 {
-				var cancelFn context.CancelFunc
-				ctx, cancelFn = context.WithTimeout(ctx, timeout)
-				defer cancelFn()
+		cmd := exec.CommandContext(ctx, "/bin/bash", "-c", command)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return types.SecretResult{Err: fmt.Errorf("running command: %w", ctxErr)}
 			}
 
-			cmd := exec.CommandContext(ctx, "/bin/bash", "-c", command)
-			cmd.Stderr = os.Stderr
-			out, err := cmd.Output()
-			if err != nil {
-				return "", false
-			}
+			return types.SecretResult{Err: fmt.Errorf("running command: %w", err)}
+		}
 
-			return string(bytes.TrimSpace(out)), true
-		}, nil
-	},
+		return types.SecretResult{Value: string(bytes.TrimSpace(out)), Found: true}
+	}, nil
 }
@@ -2,6 +2,7 @@ package bash
 
 import (
 	"context"
+	"os/exec"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -93,3 +94,36 @@ func TestSource_SecretGetterFactory(t *testing.T) {
 		require.Equal(t, "command cannot be empty", err.Error())
 	})
 }
+
+func TestSource_SecretResolverFactory(t *testing.T) {
+	t.Run("reports the exit code", func(t *testing.T) {
+		resolver, err := Source.SecretResolverFactory(&Config{Command: "exit 3"})
+		require.NoError(t, err)
+
+		res := resolver(context.Background())
+		require.False(t, res.Found)
+
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, res.Err, &exitErr)
+		require.Equal(t, 3, exitErr.ExitCode())
+	})
+
+	t.Run("reports the timeout", func(t *testing.T) {
+		resolver, err := Source.SecretResolverFactory(&Config{Command: "sleep 2", TimeoutMS: 100})
+		require.NoError(t, err)
+
+		res := resolver(context.Background())
+		require.False(t, res.Found)
+		require.ErrorIs(t, res.Err, context.DeadlineExceeded)
+	})
+
+	t.Run("returns the value", func(t *testing.T) {
+		resolver, err := Source.SecretResolverFactory(&Config{Command: "echo test_secret"})
+		require.NoError(t, err)
+
+		res := resolver(context.Background())
+		require.NoError(t, res.Err)
+		require.True(t, res.Found)
+		require.Equal(t, "test_secret", res.Value)
+	})
+}
@@ -33,36 +33,53 @@ var Source = types.SecretSource{
 		return &Config{}
 	},
 	SecretGetterFactory: func(cfg types.SourceConfig) (types.SecretGetter, error) {
-		var ref string
-		if tCfg, ok := cfg.(*Config); ok {
-			ref = tCfg.Ref
-		} else {
-			return nil, errors.New("invalid config")
+		r, err := newSecretResolver(cfg)
+		if err != nil {
+			return nil, err
 		}
 
-		if ref == "" {
-			return nil, errors.New("ref cannot be empty")
-		}
+		return r.Getter(), nil
+	},
+	SecretResolverFactory: newSecretResolver,
+}
 
-		return func(ctx context.Context) (string, bool) {
-			_, err := stdexec.LookPath("op")
-			if err != nil {
-				log.Logger.Error("1Password CLI not found", "error", err)
-				return "", false
-			}
+var errNoAccounts = errors.New("no 1Password accounts available")
 
-			if out, err := exec.CommandContext(ctx, "op", "account", "list"); err != nil {
-				return "", false
-			} else if len(bytes.TrimSpace(out)) == 0 {
-				_, _ = fmt.Fprintf(os.Stderr, "You can use 1Password by turning on the 1Password desktop app integration by following this instructions:\nhttps://developer.1password.com/docs/cli/get-started/#step-2-turn-on-the-1password-desktop-app-integration\n\n")
-				return "", false
-			}
+func newSecretResolver(cfg types.SourceConfig) (types.SecretResolver, error) {
+	var ref string
+	if tCfg, ok := cfg.(*Config); ok {
+		ref = tCfg.Ref
+	} else {
+		return nil, errors.New("invalid config")
+	}
 
-			if out, err := exec.CommandContextQ(ctx, "op", "read", ref); err == nil {
-				return string(bytes.TrimSpace(out)), true
-			}
+	if ref == "" {
+		return nil, errors.New("ref cannot be empty")
+	}
 
-			return "", false
-		}, nil
-	},
+	return func(ctx context.Context) types.SecretResult {
+		_, err := stdexec.LookPath("op")
+		if err != nil {
+			log.FromContext(ctx).Error("1Password CLI not found", "error", err)
+			return types.SecretResult{Err: fmt.Errorf("1Password CLI not found: %w", err)}
+		}
+
+		if out, err := exec.CommandContext(ctx, "op", "account", "list"); err != nil {
+			return types.SecretResult{Err: fmt.Errorf("listing 1Password accounts: %w", err)}
+		} else if len(bytes.TrimSpace(out)) == 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "You can use 1Password by turning on the 1Password desktop app integration by following this instructions:\nhttps://developer.1password.com/docs/cli/get-started/#step-2-turn-on-the-1password-desktop-app-integration\n\n")
+			return types.SecretResult{Err: errNoAccounts}
+		}
+
+		out, err := exec.CommandContextQ(ctx, "op", "read", ref)
+		if err != nil {
+			return types.SecretResult{Err: fmt.Errorf("reading %s: %w", ref, err)}
+		}
+
+		return types.SecretResult{
+			Value:    string(bytes.TrimSpace(out)),
+			Found:    true,
+			Metadata: types.SecretMetadata{Reference: ref},
+		}
+	}, nil
 }
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -26,3 +27,16 @@ func TestSource_SecretGetterFactory(t *testing.T) {
 		require.Equal(t, "ref cannot be empty", err.Error())
 	})
 }
+
+func TestSource_SecretResolverFactory(t *testing.T) {
+	t.Run("reports missing CLI", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+
+		resolver, err := Source.SecretResolverFactory(&Config{Ref: "op://vault/item/field"})
+		require.NoError(t, err)
+
+		res := resolver(context.Background())
+		require.False(t, res.Found)
+		require.ErrorContains(t, res.Err, "1Password CLI not found")
+	})
+}
@@ -3,6 +3,7 @@ package sources
 import (
 	"maps"
 	"slices"
+	"sync"
 
 	"github.com/jcchavezs/pakay/internal/sources/bash"
 	"github.com/jcchavezs/pakay/internal/sources/env"
@@ -13,19 +14,26 @@ import (
 )
 
 var (
+	mu      sync.RWMutex
 	sources = map[string]types.SecretSource{}
 )
 
 func Register(p types.SecretSource) {
+	mu.Lock()
+	defer mu.Unlock()
 	sources[p.ConfigFactory().Type()] = p
 }
 
 func Get(name string) (types.SecretSource, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
 	p, ok := sources[name]
 	return p, ok
 }
 
 func GetAll() []types.SecretSource {
+	mu.RLock()
+	defer mu.RUnlock()
 	return slices.Collect(maps.Values(sources))
 }
 
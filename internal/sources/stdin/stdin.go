@@ -35,28 +35,39 @@ var Source = types.SecretSource{
 		return &Config{}
 	},
 	SecretGetterFactory: func(cfg types.SourceConfig) (types.SecretGetter, error) {
-		var prompt string
-		if tCfg, ok := cfg.(*Config); ok {
-			prompt = tCfg.Prompt
-		} else {
-			return nil, errors.New("invalid config")
+		r, err := newSecretResolver(cfg)
+		if err != nil {
+			return nil, err
 		}
 
-		if prompt == "" {
-			return nil, errors.New("prompt cannot be empty")
+		return r.Getter(), nil
+	},
+	SecretResolverFactory: newSecretResolver,
+	Interactive:           true,
+}
+
+func newSecretResolver(cfg types.SourceConfig) (types.SecretResolver, error) {
+	var prompt string
+	if tCfg, ok := cfg.(*Config); ok {
+		prompt = tCfg.Prompt
+	} else {
+		return nil, errors.New("invalid config")
+	}
+
+	if prompt == "" {
+		return nil, errors.New("prompt cannot be empty")
+	}
+
+	return func(ctx context.Context) types.SecretResult {
+		_, _ = fmt.Printf("%s: ", prompt)
+		input, err := readPassword(int(os.Stdin.Fd()))
+		_, _ = fmt.Println("")
+		if err != nil {
+			log.FromContext(ctx).Error("failed to read from stdin", "error", err)
+			return types.SecretResult{Err: fmt.Errorf("reading from stdin: %w", err)}
 		}
 
-		return func(ctx context.Context) (string, bool) {
-			_, _ = fmt.Printf("%s: ", prompt)
-			input, err := readPassword(int(os.Stdin.Fd()))
-			_, _ = fmt.Println("")
-			if err != nil {
-				log.Logger.Error("failed to read from stdin", "error", err)
-				return "", false
-			}
-
-			input = bytes.TrimSpace(input)
-			return string(input), len(input) > 0
-		}, nil
-	},
+		input = bytes.TrimSpace(input)
+		return types.SecretResult{Value: string(input), Found: len(input) > 0}
+	}, nil
 }
@@ -84,3 +84,16 @@ func TestSource_SecretGetterFactory(t *testing.T) {
 		require.False(t, ok)
 	})
 }
+
+func TestSource_SecretResolverFactory(t *testing.T) {
+	readPassword = func(int) ([]byte, error) {
+		return nil, errors.New("invalid input")
+	}
+
+	resolver, err := Source.SecretResolverFactory(&Config{Prompt: "Insert the password"})
+	require.NoError(t, err)
+
+	res := resolver(context.Background())
+	require.False(t, res.Found)
+	require.ErrorContains(t, res.Err, "reading from stdin: invalid input")
+}
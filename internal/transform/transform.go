@@ -0,0 +1,233 @@
+// Package transform applies the transformation steps declared on a source to the
+// values it returns. Errors describe the failing step without revealing the value.
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jcchavezs/pakay/internal/parser"
+)
+
+// Transformer transforms a value returned by a source.
+type Transformer func(value string) (string, error)
+
+type stepFunc func(value string) (string, error)
+
+// New returns a transformer applying the steps in order. It returns an error when
+// a step is unknown or its argument is invalid.
+func New(steps []parser.TransformStep) (Transformer, error) {
+	fns := make([]stepFunc, 0, len(steps))
+	for i, step := range steps {
+		fn, err := newStep(step)
+		if err != nil {
+			return nil, fmt.Errorf("step #%d (%s): %w", i, step.Name, err)
+		}
+
+		fns = append(fns, fn)
+	}
+
+	return func(value string) (string, error) {
+		var err error
+		for i, fn := range fns {
+			if value, err = fn(value); err != nil {
+				return "", fmt.Errorf("step #%d (%s): %w", i, steps[i].Name, err)
+			}
+		}
+
+		return value, nil
+	}, nil
+}
+
+func newStep(step parser.TransformStep) (stepFunc, error) {
+	requireArg := func() error {
+		if step.Arg == "" {
+			return errors.New("missing argument")
+		}
+		return nil
+	}
+
+	switch step.Name {
+	case parser.TransformBase64Decode:
+		return base64Decode, nil
+	case parser.TransformBase64Encode:
+		return func(v string) (string, error) {
+			return base64.StdEncoding.EncodeToString([]byte(v)), nil
+		}, nil
+	case parser.TransformTrim:
+		return func(v string) (string, error) {
+			return strings.TrimSpace(v), nil
+		}, nil
+	case parser.TransformFirstLine:
+		return func(v string) (string, error) {
+			line, _, _ := strings.Cut(v, "\n")
+			return strings.TrimSuffix(line, "\r"), nil
+		}, nil
+	case parser.TransformPrefix:
+		return func(v string) (string, error) {
+			return step.Arg + v, nil
+		}, nil
+	case parser.TransformSuffix:
+		return func(v string) (string, error) {
+			return v + step.Arg, nil
+		}, nil
+	case parser.TransformRegexExtract:
+		if err := requireArg(); err != nil {
+			return nil, err
+		}
+
+		re, err := regexp.Compile(step.Arg)
+		if err != nil {
+			return nil, fmt.Errorf("compiling regex: %w", err)
+		}
+
+		return func(v string) (string, error) {
+			m := re.FindStringSubmatch(v)
+			switch {
+			case m == nil:
+				return "", fmt.Errorf("no match for %q", step.Arg)
+			case len(m) > 1:
+				return m[1], nil
+			default:
+				return m[0], nil
+			}
+		}, nil
+	case parser.TransformJSONPath, parser.TransformYAMLPath:
+		if err := requireArg(); err != nil {
+			return nil, err
+		}
+
+		p, err := parsePath(step.Arg)
+		if err != nil {
+			return nil, err
+		}
+
+		unmarshal, format := unmarshalJSON, "JSON"
+		if step.Name == parser.TransformYAMLPath {
+			unmarshal, format = yaml.Unmarshal, "YAML"
+		}
+
+		return func(v string) (string, error) {
+			var doc any
+			if err := unmarshal([]byte(v), &doc); err != nil {
+				return "", fmt.Errorf("value is not %s", format)
+			}
+
+			return p.lookup(doc)
+		}, nil
+	default:
+		return nil, errors.New("unknown step")
+	}
+}
+
+// unmarshalJSON decodes the numbers as json.Number so they are returned as written,
+// e.g. 12345678 rather than 1.2345678e+07.
+func unmarshalJSON(data []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return errors.New("unexpected data after the value")
+	}
+
+	return nil
+}
+
+func base64Decode(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		// values are often stored without padding
+		if b, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+			return "", errors.New("value is not base64 encoded")
+		}
+	}
+
+	return string(b), nil
+}
+
+// path is a sequence of keys and indexes, e.g. $.data.tokens[0].value.
+type path struct {
+	raw      string
+	segments []any
+}
+
+var pathSegmentRe = regexp.MustCompile(`^(?:\.?([^.\[\]]+)|\[(\d+)\])`)
+
+func parsePath(raw string) (path, error) {
+	p := path{raw: raw}
+
+	rest := strings.TrimPrefix(raw, "$")
+	for rest != "" {
+		m := pathSegmentRe.FindStringSubmatch(rest)
+		if m == nil {
+			return path{}, fmt.Errorf("invalid path %q", raw)
+		}
+
+		if m[2] != "" {
+			idx, err := strconv.Atoi(m[2])
+			if err != nil {
+				return path{}, fmt.Errorf("invalid index in path %q", raw)
+			}
+			p.segments = append(p.segments, idx)
+		} else {
+			p.segments = append(p.segments, m[1])
+		}
+
+		rest = rest[len(m[0]):]
+	}
+
+	return p, nil
+}
+
+// lookup returns the value at the path, scalars as they are and objects or arrays
+// encoded as JSON.
+func (p path) lookup(doc any) (string, error) {
+	cur := doc
+	for _, seg := range p.segments {
+		switch seg := seg.(type) {
+		case string:
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("%q: %s is not an object key", p.raw, seg)
+			}
+
+			if cur, ok = obj[seg]; !ok {
+				return "", fmt.Errorf("%q: key %s not found", p.raw, seg)
+			}
+		case int:
+			arr, ok := cur.([]any)
+			if !ok || seg >= len(arr) {
+				return "", fmt.Errorf("%q: index %d not found", p.raw, seg)
+			}
+
+			cur = arr[seg]
+		}
+	}
+
+	switch v := cur.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", fmt.Errorf("%q: value is null", p.raw)
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("%q: encoding value", p.raw)
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	for name, steps := range map[string][]parser.TransformStep{
+		"unknown step":  {{Name: "rot13"}},
+		"missing arg":   {{Name: parser.TransformJSONPath}},
+		"invalid regex": {{Name: parser.TransformRegexExtract, Arg: "("}},
+		"invalid path":  {{Name: parser.TransformYAMLPath, Arg: "$.a[b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(steps)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestTransformer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		steps    []parser.TransformStep
+		value    string
+		expected string
+	}{
+		{"trim", []parser.TransformStep{{Name: "trim"}}, " abc\n", "abc"},
+		{"first line", []parser.TransformStep{{Name: "first_line"}}, "abc\r\ndef", "abc"},
+		{"base64 decode", []parser.TransformStep{{Name: "base64_decode"}}, "aGVsbG8=\n", "hello"},
+		{"base64 decode without padding", []parser.TransformStep{{Name: "base64_decode"}}, "aGVsbG8", "hello"},
+		{"base64 encode", []parser.TransformStep{{Name: "base64_encode"}}, "hello", "aGVsbG8="},
+		{"prefix and suffix", []parser.TransformStep{{Name: "prefix", Arg: "Bearer "}, {Name: "suffix", Arg: "!"}}, "tok", "Bearer tok!"},
+		{"regex extract group", []parser.TransformStep{{Name: "regex_extract", Arg: `token=(\w+)`}}, "user=a token=xyz", "xyz"},
+		{"regex extract match", []parser.TransformStep{{Name: "regex_extract", Arg: `\d+`}}, "abc123def", "123"},
+		{"json path", []parser.TransformStep{{Name: "json_path", Arg: "$.data.tokens[1].value"}}, `{"data":{"tokens":[{"value":"a"},{"value":"b"}]}}`, "b"},
+		{"json path without root", []parser.TransformStep{{Name: "json_path", Arg: "data.port"}}, `{"data":{"port":5432}}`, "5432"},
+		{"json path integer", []parser.TransformStep{{Name: "json_path", Arg: "$.pin"}}, `{"pin":12345678}`, "12345678"},
+		{"json path large integer", []parser.TransformStep{{Name: "json_path", Arg: "$.id"}}, `{"id":1234567890123456789}`, "1234567890123456789"},
+		{"json path decimal", []parser.TransformStep{{Name: "json_path", Arg: "$.ratio"}}, `{"ratio":0.10}`, "0.10"},
+		{"json path object with numbers", []parser.TransformStep{{Name: "json_path", Arg: "$.data"}}, `{"data":{"id":1234567890123456789}}`, `{"id":1234567890123456789}`},
+		{"json path object", []parser.TransformStep{{Name: "json_path", Arg: "$.data"}}, `{"data":{"a":"b"}}`, `{"a":"b"}`},
+		{"yaml path", []parser.TransformStep{{Name: "yaml_path", Arg: "$.db.password"}}, "db:\n  password: s3cr3t\n", "s3cr3t"},
+		{"pipeline", []parser.TransformStep{{Name: "base64_decode"}, {Name: "json_path", Arg: "$.token"}}, "eyJ0b2tlbiI6Inh5eiJ9", "xyz"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, err := New(tc.steps)
+			require.NoError(t, err)
+
+			v, err := tr(tc.value)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestTransformerErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		steps []parser.TransformStep
+		value string
+	}{
+		{"not base64", []parser.TransformStep{{Name: "base64_decode"}}, "s3cr3t!!"},
+		{"not json", []parser.TransformStep{{Name: "json_path", Arg: "$.a"}}, "s3cr3t"},
+		{"trailing data", []parser.TransformStep{{Name: "json_path", Arg: "$.a"}}, `{"a":"b"} s3cr3t`},
+		{"missing key", []parser.TransformStep{{Name: "json_path", Arg: "$.b"}}, `{"a":"s3cr3t"}`},
+		{"no match", []parser.TransformStep{{Name: "regex_extract", Arg: `^\d+$`}}, "s3cr3t"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, err := New(tc.steps)
+			require.NoError(t, err)
+
+			_, err = tr(tc.value)
+			require.ErrorContains(t, err, "step #0")
+			require.NotContains(t, err.Error(), "s3cr3t")
+		})
+	}
+}
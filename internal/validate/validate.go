@@ -0,0 +1,257 @@
+// Package validate checks the values returned by the sources against the rules
+// declared in the manifest. Errors describe why a value is invalid without
+// revealing it.
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+)
+
+// Validator returns an error when the value doesn't satisfy the rules.
+type Validator func(value string) error
+
+var charsets = map[string]func(rune) bool{
+	"alphanumeric": func(r rune) bool { return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) },
+	"hex":          func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+	"base64":       func(r rune) bool { return isBase64Rune(r) || r == '+' || r == '/' || r == '=' },
+	"base64url":    func(r rune) bool { return isBase64Rune(r) || r == '-' || r == '_' || r == '=' },
+	"ascii":        func(r rune) bool { return r < utf8.RuneSelf },
+	"printable":    func(r rune) bool { return r < utf8.RuneSelf && unicode.IsPrint(r) },
+}
+
+var formats = map[string]func(string) error{
+	"url":             checkURL,
+	"uuid":            checkUUID,
+	"base64":          checkBase64,
+	"jwt":             checkJWT,
+	"pem_certificate": checkPEMCertificate,
+	"pem_private_key": checkPEMPrivateKey,
+	"json":            checkJSON,
+}
+
+// New returns a validator enforcing all the given rules. It returns an error when
+// the rules themselves are invalid.
+func New(rules parser.ValidationRules) (Validator, error) {
+	var checks []func(string) error
+
+	switch {
+	case rules.MinLength < 0 || rules.MaxLength < 0:
+		return nil, errors.New("lengths cannot be negative")
+	case rules.MaxLength > 0 && rules.MinLength > rules.MaxLength:
+		return nil, errors.New("min_length cannot be greater than max_length")
+	}
+
+	if rules.MinLength > 0 {
+		checks = append(checks, func(v string) error {
+			if n := utf8.RuneCountInString(v); n < rules.MinLength {
+				return fmt.Errorf("length %d is less than %d", n, rules.MinLength)
+			}
+			return nil
+		})
+	}
+
+	if rules.MaxLength > 0 {
+		checks = append(checks, func(v string) error {
+			if n := utf8.RuneCountInString(v); n > rules.MaxLength {
+				return fmt.Errorf("length %d is greater than %d", n, rules.MaxLength)
+			}
+			return nil
+		})
+	}
+
+	if rules.Charset != "" {
+		inCharset, ok := charsets[rules.Charset]
+		if !ok {
+			return nil, fmt.Errorf("unknown charset: %s", rules.Charset)
+		}
+
+		checks = append(checks, func(v string) error {
+			if i := strings.IndexFunc(v, func(r rune) bool { return !inCharset(r) }); i >= 0 {
+				return fmt.Errorf("character at position %d is not in the %s charset", i, rules.Charset)
+			}
+			return nil
+		})
+	}
+
+	if rules.Regex != "" {
+		re, err := regexp.Compile(rules.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("compiling regex: %w", err)
+		}
+
+		checks = append(checks, func(v string) error {
+			if !re.MatchString(v) {
+				return fmt.Errorf("does not match %q", rules.Regex)
+			}
+			return nil
+		})
+	}
+
+	if rules.Format != "" {
+		check, ok := formats[rules.Format]
+		if !ok {
+			return nil, fmt.Errorf("unknown format: %s", rules.Format)
+		}
+
+		checks = append(checks, func(v string) error {
+			if err := check(v); err != nil {
+				return fmt.Errorf("is not a valid %s: %w", rules.Format, err)
+			}
+			return nil
+		})
+	}
+
+	switch rules.OnFailure {
+	case "", parser.OnFailureNotFound, parser.OnFailureError:
+	default:
+		return nil, fmt.Errorf("unknown on_failure: %s", rules.OnFailure)
+	}
+
+	return func(v string) error {
+		for _, check := range checks {
+			if err := check(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func isBase64Rune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// the errors below must not include the value nor errors from the standard library
+// that could quote it.
+
+func checkURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return errors.New("malformed URL")
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return errors.New("missing scheme or host")
+	}
+
+	return nil
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func checkUUID(v string) error {
+	if !uuidRe.MatchString(v) {
+		return errors.New("expected 8-4-4-4-12 hexadecimal digits")
+	}
+
+	return nil
+}
+
+func checkBase64(v string) error {
+	if _, err := base64.StdEncoding.DecodeString(v); err != nil {
+		return errors.New("malformed base64")
+	}
+
+	return nil
+}
+
+func checkJWT(v string) error {
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected 3 segments, got %d", len(parts))
+	}
+
+	for i, part := range parts[:2] {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return fmt.Errorf("segment %d is not base64url encoded", i+1)
+		}
+
+		if !json.Valid(b) {
+			return fmt.Errorf("segment %d is not JSON", i+1)
+		}
+	}
+
+	if _, err := base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return errors.New("signature is not base64url encoded")
+	}
+
+	return nil
+}
+
+func decodePEM(v string) (*pem.Block, error) {
+	block, rest := pem.Decode([]byte(v))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, errors.New("unexpected data after the PEM block")
+	}
+
+	return block, nil
+}
+
+func checkPEMCertificate(v string) error {
+	block, err := decodePEM(v)
+	if err != nil {
+		return err
+	}
+
+	if block.Type != "CERTIFICATE" {
+		return fmt.Errorf("unexpected PEM block type %s", block.Type)
+	}
+
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return errors.New("malformed certificate")
+	}
+
+	return nil
+}
+
+func checkPEMPrivateKey(v string) error {
+	block, err := decodePEM(v)
+	if err != nil {
+		return err
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		_, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		_, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		_, err = x509.ParseECPrivateKey(block.Bytes)
+	case "OPENSSH PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+		// not parsed by the standard library
+	default:
+		return fmt.Errorf("unexpected PEM block type %s", block.Type)
+	}
+
+	if err != nil {
+		return errors.New("malformed private key")
+	}
+
+	return nil
+}
+
+func checkJSON(v string) error {
+	if !json.Valid([]byte(v)) {
+		return errors.New("malformed JSON")
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package validate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	for name, rules := range map[string]parser.ValidationRules{
+		"negative length":   {MinLength: -1},
+		"min above max":     {MinLength: 5, MaxLength: 4},
+		"unknown charset":   {Charset: "klingon"},
+		"unknown format":    {Format: "xml"},
+		"invalid regex":     {Regex: "("},
+		"unknown onfailure": {OnFailure: "panic"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(rules)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+
+	testCases := []struct {
+		name    string
+		rules   parser.ValidationRules
+		valid   []string
+		invalid []string
+	}{
+		{"length", parser.ValidationRules{MinLength: 3, MaxLength: 5}, []string{"abc", "abcde"}, []string{"ab", "abcdef"}},
+		{"regex", parser.ValidationRules{Regex: `^ghp_\w+$`}, []string{"ghp_abc"}, []string{"gho_abc", "ghp_abc\n"}},
+		{"charset", parser.ValidationRules{Charset: "alphanumeric"}, []string{"abc123"}, []string{"abc 123", "abc\n"}},
+		{"hex", parser.ValidationRules{Charset: "hex"}, []string{"deadBEEF"}, []string{"xyz"}},
+		{"url", parser.ValidationRules{Format: "url"}, []string{"https://example.com/path"}, []string{"example.com", "://"}},
+		{"uuid", parser.ValidationRules{Format: "uuid"}, []string{"123e4567-e89b-12d3-a456-426614174000"}, []string{"123e4567"}},
+		{"base64", parser.ValidationRules{Format: "base64"}, []string{"aGVsbG8="}, []string{"aGVsbG8"}},
+		{"jwt", parser.ValidationRules{Format: "jwt"}, []string{"eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"}, []string{"a.b", "YQ.YQ.YQ"}},
+		{"json", parser.ValidationRules{Format: "json"}, []string{`{"a":1}`}, []string{`{"a":`}},
+		{"pem certificate", parser.ValidationRules{Format: "pem_certificate"}, []string{certPEM}, []string{keyPEM, "garbage"}},
+		{"pem private key", parser.ValidationRules{Format: "pem_private_key"}, []string{keyPEM}, []string{certPEM, "garbage"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := New(tc.rules)
+			require.NoError(t, err)
+
+			for _, val := range tc.valid {
+				require.NoError(t, v(val))
+			}
+
+			for _, val := range tc.invalid {
+				err := v(val)
+				require.Error(t, err)
+				require.NotContains(t, err.Error(), val)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package pakay
+
+import (
+	"github.com/jcchavezs/pakay/internal/parser"
+)
+
+// NamingRules derive the configuration of the sources declared without one, e.g.
+// `- type: env`, from the name of their secret, by source type. By default the key
+// of the env sources is the upper cased name of the secret, e.g. MY_API_TOKEN for
+// my_api_token.
+type NamingRules map[string]NamingRule
+
+// NamingRule derives the configuration of a source type from the name of a secret.
+type NamingRule struct {
+	// Prefix is prepended to the converted name, e.g. MYAPP_.
+	Prefix string
+	// Case is one of "upper", "lower" or "kebab". The name is used as declared when
+	// not set.
+	Case string
+	// Config holds the fields of the source configuration where {name} is replaced
+	// by the prefixed and converted name, e.g. {"ref": "op://vault/{name}/password"}.
+	Config map[string]string
+}
+
+func (rs NamingRules) toParser() parser.NamingRules {
+	if rs == nil {
+		return nil
+	}
+
+	out := make(parser.NamingRules, len(rs))
+	for typ, r := range rs {
+		out[typ] = parser.NamingRule{Prefix: r.Prefix, Case: r.Case, Config: r.Config}
+	}
+
+	return out
+}
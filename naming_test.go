@@ -0,0 +1,57 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamingRules(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("MY_API_TOKEN", "default_value")
+	t.Setenv("MYAPP_MY_API_TOKEN", "prefixed_value")
+
+	t.Run("default", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte("- name: my_api_token\n  sources:\n  - type: env\n")))
+
+		val, err := r.GetSecretE(ctx, "my_api_token")
+		require.NoError(t, err)
+		require.Equal(t, "default_value", val)
+	})
+
+	t.Run("from load options", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsWithOptions(SecretsConfig{{
+			Name:    "my_api_token",
+			Sources: []SecretSource{{TypedConfig: &EnvConfig{}}},
+		}}, LoadOptions{Naming: NamingRules{"env": {Prefix: "MYAPP_"}}}))
+
+		val, err := r.GetSecretE(ctx, "my_api_token")
+		require.NoError(t, err)
+		require.Equal(t, "prefixed_value", val)
+	})
+
+	t.Run("manifest takes precedence", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(`---
+naming:
+  env:
+    prefix: MYAPP_
+secrets:
+- name: my_api_token
+  sources:
+  - type: env
+`), LoadConfigOptions{LoadOptions: LoadOptions{Naming: NamingRules{"env": {Prefix: "OTHER_"}}}}))
+
+		trace, err := r.Explain(ctx, "my_api_token", ExplainOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "env: MYAPP_MY_API_TOKEN", trace.Source.Description)
+	})
+
+	t.Run("no rule", func(t *testing.T) {
+		err := NewRegistry().LoadSecretsConfig([]byte("- name: my_api_token\n  sources:\n  - type: 1password\n"))
+		require.ErrorContains(t, err, "no naming rule")
+	})
+}
@@ -0,0 +1,38 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecretsConfigWithOverlays(t *testing.T) {
+	base := []byte(`---
+- name: api_token
+  sources:
+  - type: static
+    static:
+      value: local_value
+`)
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfigWithOptions(base, LoadConfigOptions{
+		Overlays: [][]byte{[]byte(`---
+- name: api_token
+  sources:
+  - type: static
+    static:
+      value: ci_value
+`)},
+	}))
+
+	val, err := r.GetSecretE(context.Background(), "api_token")
+	require.NoError(t, err)
+	require.Equal(t, "ci_value", val)
+
+	err = NewRegistry().LoadSecretsConfigWithOptions(base, LoadConfigOptions{
+		Overlays: [][]byte{[]byte("- name: api_key\n  order: [static]\n")},
+	})
+	require.ErrorContains(t, err, `overlay #0: unknown secret "api_key"`)
+}
@@ -0,0 +1,115 @@
+package pakay
+
+import (
+	"context"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/jcchavezs/pakay/internal/secrets"
+	"github.com/jcchavezs/pakay/types"
+)
+
+const (
+	defaultBackoffInitial    = 100 * time.Millisecond
+	defaultBackoffMultiplier = 2
+)
+
+// SourcePolicy configures how a source is invoked when resolving a secret. It is
+// enforced for every source regardless of its type.
+type SourcePolicy struct {
+	// Timeout of each attempt. Zero means no timeout.
+	Timeout time.Duration
+	// MaxAttempts is the number of times a failing source is invoked. Sources
+	// returning no value are not retried. Defaults to 1.
+	MaxAttempts int
+	Backoff     BackoffPolicy
+}
+
+// BackoffPolicy configures the exponential wait between attempts.
+type BackoffPolicy struct {
+	// Initial wait after the first attempt. Defaults to 100ms.
+	Initial time.Duration
+	// Max caps the wait between attempts. Zero means no cap.
+	Max time.Duration
+	// Multiplier applied to the wait after each attempt. Defaults to 2.
+	Multiplier float64
+}
+
+func (p *SourcePolicy) toParser() *parser.SourcePolicy {
+	if p == nil {
+		return nil
+	}
+
+	return &parser.SourcePolicy{
+		Timeout:     p.Timeout,
+		MaxAttempts: p.MaxAttempts,
+		Backoff: parser.BackoffPolicy{
+			Initial:    p.Backoff.Initial,
+			Max:        p.Backoff.Max,
+			Multiplier: p.Backoff.Multiplier,
+		},
+	}
+}
+
+// backoff returns the wait before the given attempt, starting at 2.
+func backoff(p parser.BackoffPolicy, attempt int) time.Duration {
+	wait := p.Initial
+	if wait == 0 {
+		wait = defaultBackoffInitial
+	}
+
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	for i := 2; i < attempt; i++ {
+		wait = time.Duration(float64(wait) * multiplier)
+		if p.Max > 0 && wait >= p.Max {
+			break
+		}
+	}
+
+	if p.Max > 0 && wait > p.Max {
+		return p.Max
+	}
+
+	return wait
+}
+
+// invokeWithPolicy invokes the getter enforcing the timeout and retries of the
+// source policy. It returns the result of the last attempt and the number of attempts.
+func (r *Registry) invokeWithPolicy(ctx context.Context, g secrets.Getter, policy *parser.SourcePolicy) (types.SecretResult, int) {
+	if policy == nil {
+		return r.invoke(ctx, g), 1
+	}
+
+	maxAttempts := max(policy.MaxAttempts, 1)
+
+	var res types.SecretResult
+	for attempt := 1; ; attempt++ {
+		res = r.invokeOnce(ctx, g, policy.Timeout)
+		if res.Err == nil || attempt == maxAttempts || ctx.Err() != nil {
+			return res, attempt
+		}
+
+		t := time.NewTimer(backoff(policy.Backoff, attempt+1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return res, attempt
+		case <-t.C:
+		}
+	}
+}
+
+func (r *Registry) invokeOnce(ctx context.Context, g secrets.Getter, timeout time.Duration) types.SecretResult {
+	if timeout <= 0 {
+		return r.invoke(ctx, g)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return r.invoke(ctx, g)
+}
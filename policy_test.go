@@ -0,0 +1,101 @@
+package pakay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/jcchavezs/pakay/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	p := parser.BackoffPolicy{}
+	require.Equal(t, 100*time.Millisecond, backoff(p, 2))
+	require.Equal(t, 200*time.Millisecond, backoff(p, 3))
+	require.Equal(t, 400*time.Millisecond, backoff(p, 4))
+
+	p = parser.BackoffPolicy{Initial: time.Second, Multiplier: 3, Max: 5 * time.Second}
+	require.Equal(t, time.Second, backoff(p, 2))
+	require.Equal(t, 3*time.Second, backoff(p, 3))
+	require.Equal(t, 5*time.Second, backoff(p, 4))
+	require.Equal(t, 5*time.Second, backoff(p, 10))
+}
+
+func TestSourcePolicy(t *testing.T) {
+	failures := 0
+	block := make(chan struct{})
+	defer close(block)
+
+	r := NewRegistry()
+	r.RegisterSource(types.SecretSource{
+		ConfigFactory: func() types.SourceConfig {
+			return &fixedConfig{}
+		},
+		SecretResolverFactory: func(cfg types.SourceConfig) (types.SecretResolver, error) {
+			val := cfg.(*fixedConfig).Value
+			return func(context.Context) types.SecretResult {
+				switch val {
+				case "blocking":
+					// ignores the context on purpose
+					<-block
+				case "flaky":
+					if failures < 2 {
+						failures++
+						return types.SecretResult{Err: errors.New("transient failure")}
+					}
+				}
+
+				return types.SecretResult{Value: val, Found: true}
+			}, nil
+		},
+	})
+
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: blocking_secret
+  sources:
+  - type: fixed
+    policy:
+      timeout: 50ms
+    fixed:
+      value: blocking
+- name: flaky_secret
+  sources:
+  - type: fixed
+    policy:
+      max_attempts: 3
+      backoff:
+        initial: 1ms
+    fixed:
+      value: flaky
+`)))
+
+	t.Run("enforces the timeout", func(t *testing.T) {
+		start := time.Now()
+		tr, err := r.Explain(context.Background(), "blocking_secret", ExplainOptions{})
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), time.Second)
+		require.Equal(t, OutcomeTimedOut, tr.Steps[0].Outcome)
+		require.ErrorIs(t, tr.Err, context.DeadlineExceeded)
+	})
+
+	t.Run("retries failures", func(t *testing.T) {
+		tr, err := r.Explain(context.Background(), "flaky_secret", ExplainOptions{})
+		require.NoError(t, err)
+		require.True(t, tr.Resolved)
+		require.Equal(t, 3, tr.Steps[0].Attempts)
+	})
+
+	t.Run("rejects invalid policies", func(t *testing.T) {
+		err := NewRegistry().LoadSecrets(SecretsConfig{{
+			Name: "test_secret",
+			Sources: []SecretSource{{
+				TypedConfig: &EnvConfig{Key: "TEST_VAR"},
+				Policy:      &SourcePolicy{Backoff: BackoffPolicy{Multiplier: 0.5}},
+			}},
+		}})
+		require.ErrorContains(t, err, `invalid policy for env source of "test_secret": backoff multiplier cannot be less than 1`)
+	})
+}
@@ -0,0 +1,154 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const profilesManifest = `---
+profiles:
+  local:
+    labels: [local]
+  ci:
+    labels: [ci]
+secrets:
+- name: api_token
+  sources:
+  - type: static
+    labels: [local]
+    static:
+      value: local_value
+  - type: static
+    labels: [ci]
+    static:
+      value: ci_value
+- name: local_only
+  sources:
+  - type: static
+    labels: [local]
+    static:
+      value: local_value
+`
+
+func TestProfiles(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no profile", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(profilesManifest)))
+		require.Equal(t, []string{"ci", "local"}, r.Profiles())
+		require.Empty(t, r.Profile())
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "local_value", val)
+	})
+
+	t.Run("from load options", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(profilesManifest), LoadConfigOptions{
+			LoadOptions: LoadOptions{Profile: "ci"},
+		}))
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "ci_value", val)
+
+		// lookups can select another profile
+		val, err = r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{Profile: "local"})
+		require.NoError(t, err)
+		require.Equal(t, "local_value", val)
+
+		_, err = r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{Profile: "prod"})
+		require.ErrorIs(t, err, ErrUnknownProfile)
+
+		trace, err := r.Explain(ctx, "local_only", ExplainOptions{})
+		require.NoError(t, err)
+		require.Equal(t, OutcomeFilteredOut, trace.Steps[0].Outcome)
+		require.Equal(t, `not in profile "ci"`, trace.Steps[0].Reason)
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv(ProfileEnvVar, "ci")
+
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(profilesManifest)))
+		require.Equal(t, "ci", r.Profile())
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "ci_value", val)
+	})
+
+	t.Run("from environment undeclared", func(t *testing.T) {
+		t.Setenv(ProfileEnvVar, "ci")
+
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  sources:
+  - type: static
+    static:
+      value: the_value
+`)))
+		require.Empty(t, r.Profile())
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "the_value", val)
+
+		report, err := r.AssertSecretsReport(ctx, AssertOptions{})
+		require.NoError(t, err)
+		require.Empty(t, report.Missing())
+
+		_, err = r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{Profile: "ci"})
+		require.ErrorIs(t, err, ErrUnknownProfile)
+	})
+
+	t.Run("key value labels", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(`---
+profiles:
+  ci:
+    labels: [env=ci]
+secrets:
+- name: api_token
+  sources:
+  - type: static
+    labels: [env=local, ci]
+    static:
+      value: local_value
+  - type: static
+    labels:
+      env: ci
+    static:
+      value: ci_value
+`), LoadConfigOptions{LoadOptions: LoadOptions{Profile: "ci"}}))
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "ci_value", val)
+	})
+
+	t.Run("assert per profile", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(profilesManifest)))
+
+		missing := map[string][]string{}
+		for _, p := range r.Profiles() {
+			report, err := r.AssertSecretsReport(ctx, AssertOptions{Profile: p})
+			require.NoError(t, err)
+			missing[p] = report.Missing()
+		}
+
+		require.Equal(t, map[string][]string{
+			"ci":    {"local_only"},
+			"local": {},
+		}, missing)
+
+		_, err := r.AssertSecretsReport(ctx, AssertOptions{Profile: "prod"})
+		require.ErrorIs(t, err, ErrUnknownProfile)
+	})
+}
@@ -0,0 +1,477 @@
+package pakay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/cache"
+	"github.com/jcchavezs/pakay/internal/condition"
+	"github.com/jcchavezs/pakay/internal/derive"
+	"github.com/jcchavezs/pakay/internal/flight"
+	"github.com/jcchavezs/pakay/internal/log"
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/jcchavezs/pakay/internal/secrets"
+	"github.com/jcchavezs/pakay/internal/securemem"
+	"github.com/jcchavezs/pakay/internal/sources"
+	"github.com/jcchavezs/pakay/internal/transform"
+	"github.com/jcchavezs/pakay/internal/validate"
+	"github.com/jcchavezs/pakay/types"
+)
+
+// Registry holds a secrets manifest along with the sources, logger and filter used
+// to resolve it. Independent registries can be loaded side by side in the same
+// binary.
+type Registry struct {
+	store *secrets.Store
+
+	// loadMu serializes the changes to the loaded secrets so their events are
+	// queued in the same order the changes are published.
+	loadMu sync.Mutex
+
+	// eventsMu guards the events queued for the subscribers and whether they are
+	// being dispatched, which happens one at a time.
+	eventsMu    sync.Mutex
+	events      []ChangeEvent
+	dispatching bool
+
+	mu          sync.RWMutex
+	logHandler  slog.Handler
+	logger      *slog.Logger
+	sources     map[string]types.SecretSource
+	subscribers map[int]func(ChangeEvent)
+	nextSubID   int
+	cacheTTL    time.Duration
+	secure      bool
+	profile     string
+	// profileFromEnv is true when the profile comes from ProfileEnvVar.
+	profileFromEnv bool
+	selector       string
+
+	cache *cache.Cache[cachedResolution]
+
+	// warned holds the names of the deprecated secrets and aliases already warned
+	// about since the last change of the manifest.
+	warned sync.Map
+
+	// secretFlight and sourceFlight deduplicate concurrent resolutions of the same
+	// secret and concurrent invocations of the same source respectively.
+	secretFlight flight.Group[Resolution]
+	sourceFlight flight.Group[types.SecretResult]
+}
+
+type RegistryOptions struct {
+	// LogHandler receives the logs emitted by the registry and its sources. It can
+	// be overridden when loading the secrets.
+	LogHandler slog.Handler
+	// FilterIn is applied to every lookup done through the registry on top of
+	// the filter passed in the lookup options.
+	FilterIn FilterIn
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return NewRegistryWithOptions(RegistryOptions{})
+}
+
+// NewRegistryWithOptions returns an empty registry configured with the given options.
+func NewRegistryWithOptions(opts RegistryOptions) *Registry {
+	r := &Registry{
+		store:       secrets.NewStore(opts.FilterIn),
+		logHandler:  opts.LogHandler,
+		sources:     map[string]types.SecretSource{},
+		subscribers: map[int]func(ChangeEvent){},
+		cache:       cache.New(cachedResolution.destroy),
+	}
+
+	if opts.LogHandler != nil {
+		r.logger = slog.New(opts.LogHandler)
+	}
+
+	return r
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry used by the package level functions.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// RegisterSource registers a secret source available only to this registry. It
+// takes precedence over a source registered globally with the same name.
+func (r *Registry) RegisterSource(p types.SecretSource) {
+	r.mu.Lock()
+	r.sources[p.ConfigFactory().Type()] = p
+	r.mu.Unlock()
+}
+
+func (r *Registry) getSource(name string) (types.SecretSource, bool) {
+	r.mu.RLock()
+	p, ok := r.sources[name]
+	r.mu.RUnlock()
+	if ok {
+		return p, true
+	}
+
+	return sources.Get(name)
+}
+
+func (r *Registry) log() *slog.Logger {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.logger == nil {
+		return log.Logger
+	}
+
+	return r.logger
+}
+
+// loadSelector returns the selector to use after loading with the given options
+// along with its filter.
+func (r *Registry) loadSelector(opts LoadOptions) (string, FilterIn, error) {
+	r.mu.RLock()
+	selector := r.selector
+	r.mu.RUnlock()
+
+	switch {
+	case opts.Selector != "":
+		selector = opts.Selector
+	case selector == "":
+		selector = os.Getenv(SelectorEnvVar)
+	}
+
+	f, err := ParseSelector(selector)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return selector, f, nil
+}
+
+func (r *Registry) applyLoadOptions(opts LoadOptions, selector string, selectorFilter FilterIn, st *secrets.State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case opts.LogHandler != nil:
+		r.logger = slog.New(opts.LogHandler)
+	case r.logHandler == nil:
+		r.logger = slog.New(log.DiscardHandler)
+	}
+
+	if opts.Cache.TTL > 0 {
+		r.cacheTTL = opts.Cache.TTL
+	}
+
+	if opts.SecureMemory {
+		r.secure = true
+	}
+
+	profile, fromEnv := r.profile, r.profileFromEnv
+	switch {
+	case opts.Profile != "":
+		profile, fromEnv = opts.Profile, false
+	case profile == "" || fromEnv:
+		profile, fromEnv = os.Getenv(ProfileEnvVar), true
+	}
+
+	// the environment is shared by all the registries of the process, the ones
+	// whose manifest doesn't declare its profile keep resolving all the sources
+	if fromEnv && profile != "" {
+		if _, ok := st.Profile(profile); !ok {
+			r.logger.Warn("Ignoring profile not declared in the manifest", "profile", profile, "env", ProfileEnvVar)
+			profile = ""
+		}
+	}
+
+	r.profileFromEnv = fromEnv
+	if profile != r.profile {
+		r.profile = profile
+		r.cache.Clear()
+	}
+
+	if selector != r.selector {
+		r.selector = selector
+		r.store.SetSelector(selectorFilter)
+		r.cache.Clear()
+	}
+}
+
+// Selector returns the label selector applied to every lookup, if any. See
+// LoadOptions.Selector.
+func (r *Registry) Selector() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.selector
+}
+
+// Profile returns the profile selecting the sources of every lookup, if any. See
+// LoadOptions.Profile.
+func (r *Registry) Profile() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.profile
+}
+
+// Profiles returns the names of the profiles declared in the manifest, sorted.
+func (r *Registry) Profiles() []string {
+	return r.store.State().Profiles()
+}
+
+func (r *Registry) secureMemory() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.secure
+}
+
+func (r *Registry) LoadSecrets(config SecretsConfig) error {
+	return r.loadSecretsFromManifestEntries(config.toManifestEntries(), LoadOptions{})
+}
+
+func (r *Registry) LoadSecretsWithOptions(config SecretsConfig, opts LoadOptions) error {
+	return r.loadSecretsFromManifestEntries(config.toManifestEntries(), opts)
+}
+
+// loadSecretsFromManifestEntries builds the secrets aside and only publishes them
+// once all of them are valid, so a failed load leaves the registry untouched.
+func (r *Registry) loadSecretsFromManifestEntries(cfg []parser.ManifestEntry, opts LoadOptions) error {
+	return r.publish(parser.Manifest{Secrets: cfg}, opts, r.store.Add)
+}
+
+// publish builds the secrets for the given manifest and hands them to apply, which
+// swaps them into the store. Subscribers are notified about the resulting changes.
+func (r *Registry) publish(m parser.Manifest, opts LoadOptions, apply func([]secrets.Secret, map[string]parser.Profile) (*secrets.State, error)) error {
+	naming := parser.DefaultNaming.Merge(opts.Naming.toParser()).Merge(m.Naming)
+	if err := naming.Apply(m.Secrets, r.getSource); err != nil {
+		return err
+	}
+
+	ss := make([]secrets.Secret, 0, len(m.Secrets))
+	for _, c := range m.Secrets {
+		s, err := r.buildSecret(c)
+		if err != nil {
+			return err
+		}
+
+		ss = append(ss, s)
+	}
+
+	if opts.SecureMemory {
+		if err := securemem.DisableCoreDumps(); err != nil {
+			return fmt.Errorf("disabling core dumps: %w", err)
+		}
+	}
+
+	// subscribers are called once loadMu is released so they can change the
+	// loaded secrets themselves
+	defer r.dispatch()
+
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+
+	selector, selectorFilter, err := r.loadSelector(opts)
+	if err != nil {
+		return err
+	}
+
+	prev, err := apply(ss, m.Profiles)
+	if err != nil {
+		return err
+	}
+
+	next := r.store.State()
+	r.applyLoadOptions(opts, selector, selectorFilter, next)
+
+	if p := r.Profile(); p != "" {
+		if _, ok := next.Profile(p); !ok {
+			r.log().Warn("Selected profile is not declared in the manifest", "profile", p)
+		}
+	}
+
+	r.notify(prev, next)
+	return nil
+}
+
+func (r *Registry) buildSecret(c parser.ManifestEntry) (secrets.Secret, error) {
+	s := secrets.Secret{
+		ManifestEntry: c,
+		Getters:       make([]secrets.Getter, 0, len(c.Sources)),
+	}
+
+	if c.Derived != nil {
+		if len(c.Sources) > 0 {
+			return secrets.Secret{}, fmt.Errorf("derived secret %q cannot declare sources", c.Name)
+		}
+
+		t, err := derive.Parse(c.Name, c.Derived.Template)
+		if err != nil {
+			return secrets.Secret{}, fmt.Errorf("invalid derived secret %q: %w", c.Name, err)
+		}
+
+		s.Template = t
+	}
+
+	if c.Validate != nil {
+		v, err := validate.New(*c.Validate)
+		if err != nil {
+			return secrets.Secret{}, fmt.Errorf("invalid validation rules for %q: %w", c.Name, err)
+		}
+
+		if c.Default != nil {
+			if err := v(*c.Default); err != nil {
+				return secrets.Secret{}, fmt.Errorf("invalid default value for %q: %w", c.Name, err)
+			}
+		}
+
+		s.Validator = v
+	}
+
+	for _, src := range c.Sources {
+		p, ok := r.getSource(src.Type)
+		if !ok {
+			return secrets.Secret{}, fmt.Errorf("unknown source: %s", src.Type)
+		}
+
+		if src.Policy != nil {
+			if err := src.Policy.Validate(); err != nil {
+				return secrets.Secret{}, fmt.Errorf("invalid policy for %s source of %q: %w", src.Type, c.Name, err)
+			}
+		}
+
+		g, err := p.NewSecretResolver(src.Config)
+		if err != nil {
+			return secrets.Secret{}, fmt.Errorf("building secret getter for %s: %w", p.ConfigFactory().Type(), err)
+		}
+
+		getter := secrets.Getter{
+			Labels:         src.Labels,
+			LabelValues:    src.LabelValues,
+			Interactive:    p.Interactive,
+			Key:            fmt.Sprintf("%s\x00%#v", src.Type, src.Config),
+			SecretResolver: g,
+		}
+
+		if len(src.Transform) > 0 {
+			t, err := transform.New(src.Transform)
+			if err != nil {
+				return secrets.Secret{}, fmt.Errorf("invalid transform for %s source of %q: %w", src.Type, c.Name, err)
+			}
+
+			getter.Transform = t
+		}
+
+		if src.When != nil {
+			cond, err := condition.New(*src.When)
+			if err != nil {
+				return secrets.Secret{}, fmt.Errorf("invalid condition for %s source of %q: %w", src.Type, c.Name, err)
+			}
+
+			getter.Condition = cond
+		}
+
+		s.Getters = append(s.Getters, getter)
+	}
+
+	return s, nil
+}
+
+// LoadSecretsConfig loads secrets from a YAML manifest provided as a byte slice.
+func (r *Registry) LoadSecretsConfig(config []byte) error {
+	return r.LoadSecretsConfigWithOptions(config, LoadConfigOptions{})
+}
+
+func (r *Registry) LoadSecretsConfigWithOptions(config []byte, opts LoadConfigOptions) error {
+	m, err := r.parseManifest(config, opts)
+	if err != nil {
+		return err
+	}
+
+	return r.publish(m, opts.LoadOptions, r.store.Add)
+}
+
+func (r *Registry) parseManifest(config []byte, opts LoadConfigOptions) (parser.Manifest, error) {
+	m, err := parser.Parse(config, parser.Options{
+		Variables: opts.Variables,
+		Sources:   r.getSource,
+		Overlays:  opts.Overlays,
+	})
+	if err != nil {
+		return parser.Manifest{}, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// GetSecret retrieves the value of a secret by its name. See GetSecret.
+func (r *Registry) GetSecret(ctx context.Context, name string) (string, bool) {
+	return r.GetSecretWithOptions(ctx, name, SecretOptions{})
+}
+
+func (r *Registry) GetSecretWithOptions(ctx context.Context, name string, opts SecretOptions) (string, bool) {
+	val, err := r.GetSecretEWithOptions(ctx, name, opts)
+	switch {
+	case errors.Is(err, ErrNotLoaded):
+		r.log().Error("Secrets haven't been loaded yet")
+	case errors.Is(err, ErrUnknownSecret):
+		r.log().Error("Unknown secret", "name", name)
+	case errors.Is(err, ErrUnknownProfile):
+		r.log().Error("Unknown profile", "name", name, "error", err)
+	case errors.Is(err, ErrInvalidSelector):
+		r.log().Error("Invalid selector", "name", name, "error", err)
+	}
+
+	return val, err == nil
+}
+
+// GetSecretE retrieves the value of a secret by its name. See GetSecretE.
+func (r *Registry) GetSecretE(ctx context.Context, name string) (string, error) {
+	return r.GetSecretEWithOptions(ctx, name, SecretOptions{})
+}
+
+func (r *Registry) GetSecretEWithOptions(ctx context.Context, name string, opts SecretOptions) (string, error) {
+	res, err := r.ResolveSecretWithOptions(ctx, name, opts)
+	return res.Value, err
+}
+
+// ResolveSecret retrieves a secret along with the metadata reported by the source
+// that resolved it. See ResolveSecret.
+func (r *Registry) ResolveSecret(ctx context.Context, name string) (Resolution, error) {
+	return r.ResolveSecretWithOptions(ctx, name, SecretOptions{})
+}
+
+func (r *Registry) ResolveSecretWithOptions(ctx context.Context, name string, opts SecretOptions) (Resolution, error) {
+	s, st, err := r.lookup(name)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	if opts.FilterIn, err = withSelector(opts.FilterIn, opts.Selector); err != nil {
+		return Resolution{}, err
+	}
+	opts.Selector = ""
+
+	return r.resolve(log.NewContext(ctx, r.log()), s, resolveOptions{SecretOptions: opts, state: st})
+}
+
+// lookup returns the declaration of a secret, looked up by its name or one of its
+// aliases, along with the current state of the registry it was found in.
+func (r *Registry) lookup(name string) (secrets.Secret, *secrets.State, error) {
+	st := r.store.State()
+	if st == nil {
+		return secrets.Secret{}, nil, ErrNotLoaded
+	}
+
+	s, ok := st.Get(name)
+	if !ok {
+		return secrets.Secret{}, nil, fmt.Errorf("%w: %q", ErrUnknownSecret, name)
+	}
+
+	r.warnDeprecated(name, s)
+	return s, st, nil
+}
@@ -0,0 +1,224 @@
+package pakay
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jcchavezs/pakay/internal/log"
+	internaltypes "github.com/jcchavezs/pakay/internal/types"
+	"github.com/jcchavezs/pakay/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("registries are independent", func(t *testing.T) {
+		r1 := NewRegistry()
+		r2 := NewRegistry()
+
+		require.NoError(t, r1.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: value_1
+`)))
+
+		require.NoError(t, r2.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: value_2
+`)))
+
+		val, ok := r1.GetSecret(context.Background(), "test_secret")
+		require.True(t, ok)
+		require.Equal(t, "value_1", val)
+
+		val, ok = r2.GetSecret(context.Background(), "test_secret")
+		require.True(t, ok)
+		require.Equal(t, "value_2", val)
+
+		_, ok = GetSecret(context.Background(), "test_secret")
+		require.False(t, ok)
+	})
+
+	t.Run("logs to its own handler", func(t *testing.T) {
+		lh := &recordHandler{}
+		r := NewRegistryWithOptions(RegistryOptions{LogHandler: lh})
+
+		_, ok := r.GetSecret(context.Background(), "test_secret")
+		require.False(t, ok)
+		require.Len(t, lh.records, 1)
+		require.Equal(t, "Secrets haven't been loaded yet", lh.records[0].Message)
+
+		require.NoError(t, r.LoadSecretsConfig([]byte(`---`)))
+
+		_, ok = r.GetSecret(context.Background(), "test_secret")
+		require.False(t, ok)
+		require.Len(t, lh.records, 2)
+		require.Equal(t, "Unknown secret", lh.records[1].Message)
+	})
+
+	t.Run("uses its own sources", func(t *testing.T) {
+		r := NewRegistry()
+		r.RegisterSource(types.SecretSource{
+			ConfigFactory: func() types.SourceConfig {
+				return &fixedConfig{}
+			},
+			SecretGetterFactory: func(cfg types.SourceConfig) (types.SecretGetter, error) {
+				return func(context.Context) (string, bool) {
+					return cfg.(*fixedConfig).Value, true
+				}, nil
+			},
+		})
+
+		config := `---
+- name: test_secret
+  sources:
+  - type: fixed
+    fixed:
+      value: fixed_value
+`
+
+		require.NoError(t, r.LoadSecretsConfig([]byte(config)))
+
+		val, ok := r.GetSecret(context.Background(), "test_secret")
+		require.True(t, ok)
+		require.Equal(t, "fixed_value", val)
+
+		err := NewRegistry().LoadSecretsConfig([]byte(config))
+		require.ErrorContains(t, err, "unknown source: fixed")
+	})
+
+	t.Run("applies its own filter", func(t *testing.T) {
+		r := NewRegistryWithOptions(RegistryOptions{
+			FilterIn: func(s Source) bool {
+				return !slices.Contains(s.Labels, "local")
+			},
+		})
+
+		require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    labels: [local]
+    static:
+      value: local_value
+  - type: static
+    labels: [ci]
+    static:
+      value: ci_value
+`)))
+
+		val, ok := r.GetSecret(context.Background(), "test_secret")
+		require.True(t, ok)
+		require.Equal(t, "ci_value", val)
+
+		_, ok = r.GetSecretWithOptions(context.Background(), "test_secret", SecretOptions{
+			FilterIn: func(s Source) bool {
+				return !slices.Contains(s.Labels, "ci")
+			},
+		})
+		require.False(t, ok)
+	})
+}
+
+type fixedConfig struct {
+	Value string `yaml:"value"`
+}
+
+func (c *fixedConfig) String() string { return c.Value }
+
+func (*fixedConfig) Type() string { return "fixed" }
+
+func (*fixedConfig) SentinelFn(internaltypes.SentinelVal) {}
+
+func TestRegistryConcurrentLoadAndRead(t *testing.T) {
+	const loaders = 8
+
+	r := NewRegistryWithOptions(RegistryOptions{LogHandler: log.DiscardHandler})
+	ctx := context.Background()
+
+	manifest := func(i int) []byte {
+		return []byte(fmt.Sprintf(`---
+- name: secret_%[1]d_a
+  sources:
+  - type: static
+    static:
+      value: value_%[1]d_a
+- name: secret_%[1]d_b
+  sources:
+  - type: static
+    static:
+      value: value_%[1]d_b
+`, i))
+	}
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+
+	for i := 0; i < loaders; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, r.LoadSecretsConfig(manifest(i)))
+		}()
+		go func() {
+			defer wg.Done()
+			// fails on the unknown source so nothing should be published
+			assert.Error(t, r.LoadSecrets(SecretsConfig{
+				{Name: fmt.Sprintf("broken_%d", i), Sources: []SecretSource{{TypedConfig: &StaticConfig{Value: "v"}}}},
+				{Name: fmt.Sprintf("broken_%d_b", i), Sources: []SecretSource{{TypedConfig: &fixedConfig{Value: "v"}}}},
+			}))
+		}()
+	}
+
+	var readers sync.WaitGroup
+	for i := 0; i < loaders; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// a manifest is either fully visible or not at all
+				if st := r.store.State(); st != nil {
+					_, okA := st.Get(fmt.Sprintf("secret_%d_a", i))
+					_, okB := st.Get(fmt.Sprintf("secret_%d_b", i))
+					assert.Equal(t, okA, okB)
+				}
+
+				if val, ok := r.GetSecret(ctx, fmt.Sprintf("secret_%d_b", i)); ok {
+					assert.Equal(t, fmt.Sprintf("value_%d_b", i), val)
+				}
+
+				_, ok := r.GetSecret(ctx, fmt.Sprintf("broken_%d", i))
+				assert.False(t, ok)
+
+				if missing, err := r.AssertSecrets(ctx); err == nil {
+					assert.Empty(t, missing)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	missing, err := r.AssertSecrets(ctx)
+	require.NoError(t, err)
+	require.Empty(t, missing)
+	require.Len(t, r.store.All(), 2*loaders)
+}
@@ -0,0 +1,136 @@
+package pakay
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/jcchavezs/pakay/internal/secrets"
+)
+
+// ChangeEvent describes how the secrets of a registry changed after a load, a
+// reload or an unload. Names are listed in declaration order.
+type ChangeEvent struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Reload parses the manifest and replaces the loaded secrets with the ones in it.
+// The new secrets are validated before being swapped in, so on error the previous
+// ones remain in place. Lookups already in flight finish with the previous definitions.
+func (r *Registry) Reload(config []byte) error {
+	return r.ReloadWithOptions(config, LoadConfigOptions{})
+}
+
+func (r *Registry) ReloadWithOptions(config []byte, opts LoadConfigOptions) error {
+	m, err := r.parseManifest(config, opts)
+	if err != nil {
+		return err
+	}
+
+	return r.publish(m, opts.LoadOptions, r.store.Replace)
+}
+
+// ReloadSecrets replaces the loaded secrets with the given config. See Reload.
+func (r *Registry) ReloadSecrets(config SecretsConfig) error {
+	return r.publish(parser.Manifest{Secrets: config.toManifestEntries()}, LoadOptions{}, r.store.Replace)
+}
+
+// Unload removes all the loaded secrets, leaving the registry as if nothing had been loaded.
+func (r *Registry) Unload() {
+	defer r.dispatch()
+
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+
+	r.notify(r.store.Reset(), nil)
+}
+
+// Subscribe registers a function to be called every time the loaded secrets change.
+// Calls happen in order, one at a time and after the change is visible to lookups,
+// from the goroutine making the change unless another one is already calling the
+// subscribers, in which case that one delivers the event after the ones before it.
+// Subscribers can load, reload or unload secrets themselves. The returned function
+// cancels the subscription.
+func (r *Registry) Subscribe(fn func(ChangeEvent)) (unsubscribe func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := r.nextSubID
+	r.nextSubID++
+	r.subscribers[id] = fn
+
+	return func() {
+		r.mu.Lock()
+		delete(r.subscribers, id)
+		r.mu.Unlock()
+	}
+}
+
+// notify invalidates the cached values of the secrets that changed, along with the
+// ones derived from them, and queues the changes for the subscribers. Callers hold
+// loadMu and call dispatch once they release it.
+func (r *Registry) notify(prev, next *secrets.State) {
+	if !secrets.SameProfiles(prev, next) {
+		r.cache.Clear()
+	}
+
+	added, removed, changed := secrets.Diff(prev, next)
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		return
+	}
+
+	r.cache.Delete(slices.Concat(removed, changed, next.Dependents(changed...))...)
+	r.warned.Clear()
+
+	r.eventsMu.Lock()
+	r.events = append(r.events, ChangeEvent{Added: added, Removed: removed, Changed: changed})
+	r.eventsMu.Unlock()
+}
+
+// dispatch calls the subscribers with the queued events, unless another goroutine
+// is already doing it, in which case that one delivers them after its own.
+func (r *Registry) dispatch() {
+	r.eventsMu.Lock()
+	if r.dispatching {
+		r.eventsMu.Unlock()
+		return
+	}
+	r.dispatching = true
+	r.eventsMu.Unlock()
+
+	// a panicking subscriber must not stop the next changes from being delivered
+	done := false
+	defer func() {
+		if !done {
+			r.eventsMu.Lock()
+			r.dispatching = false
+			r.eventsMu.Unlock()
+		}
+	}()
+
+	for {
+		r.eventsMu.Lock()
+		if len(r.events) == 0 {
+			r.dispatching, done = false, true
+			r.eventsMu.Unlock()
+			return
+		}
+
+		ev := r.events[0]
+		r.events = r.events[1:]
+		r.eventsMu.Unlock()
+
+		r.mu.RLock()
+		subscribers := make([]func(ChangeEvent), 0, len(r.subscribers))
+		for _, id := range slices.Sorted(maps.Keys(r.subscribers)) {
+			subscribers = append(subscribers, r.subscribers[id])
+		}
+		r.mu.RUnlock()
+
+		for _, fn := range subscribers {
+			fn(ev)
+		}
+	}
+}
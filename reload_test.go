@@ -0,0 +1,196 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jcchavezs/pakay/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replaces the loaded secrets", func(t *testing.T) {
+		r := NewRegistry()
+
+		var events []ChangeEvent
+		unsubscribe := r.Subscribe(func(ev ChangeEvent) {
+			events = append(events, ev)
+		})
+
+		require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: kept
+  sources:
+  - type: static
+    static:
+      value: kept_value
+- name: changed
+  sources:
+  - type: static
+    static:
+      value: old_value
+- name: removed
+  sources:
+  - type: static
+    static:
+      value: removed_value
+`)))
+
+		require.NoError(t, r.Reload([]byte(`---
+- name: kept
+  sources:
+  - type: static
+    static:
+      value: kept_value
+- name: changed
+  sources:
+  - type: static
+    static:
+      value: new_value
+- name: added
+  sources:
+  - type: static
+    static:
+      value: added_value
+`)))
+
+		val, ok := r.GetSecret(ctx, "changed")
+		require.True(t, ok)
+		require.Equal(t, "new_value", val)
+
+		_, ok = r.GetSecret(ctx, "removed")
+		require.False(t, ok)
+
+		require.Equal(t, []ChangeEvent{
+			{Added: []string{"kept", "changed", "removed"}},
+			{Added: []string{"added"}, Removed: []string{"removed"}, Changed: []string{"changed"}},
+		}, events)
+
+		unsubscribe()
+		r.Unload()
+		require.Len(t, events, 2)
+
+		_, err := r.AssertSecrets(ctx)
+		require.ErrorContains(t, err, "secrets haven't been loaded yet")
+	})
+
+	t.Run("subscribers can change the secrets", func(t *testing.T) {
+		r := NewRegistry()
+
+		var events []ChangeEvent
+		r.Subscribe(func(ev ChangeEvent) {
+			events = append(events, ev)
+			if len(ev.Added) > 0 && ev.Added[0] == "first" {
+				require.NoError(t, r.Reload([]byte("- name: second\n  sources:\n  - type: static\n    static:\n      value: second_value\n")))
+				r.Unload()
+			}
+		})
+
+		require.NoError(t, r.LoadSecretsConfig([]byte("- name: first\n  sources:\n  - type: static\n    static:\n      value: first_value\n")))
+		require.Equal(t, []ChangeEvent{
+			{Added: []string{"first"}},
+			{Added: []string{"second"}, Removed: []string{"first"}},
+			{Removed: []string{"second"}},
+		}, events)
+
+		_, err := r.GetSecretE(ctx, "second")
+		require.ErrorIs(t, err, ErrNotLoaded)
+	})
+
+	t.Run("keeps previous secrets on failure", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: test_value
+`)))
+
+		err := r.Reload([]byte(`---
+- name: test_secret
+  sources:
+  - type: unknown_source
+`))
+		require.ErrorContains(t, err, "unknown source: unknown_source")
+
+		val, ok := r.GetSecret(ctx, "test_secret")
+		require.True(t, ok)
+		require.Equal(t, "test_value", val)
+	})
+
+	t.Run("in flight lookups use the previous definition", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		r := NewRegistry()
+		r.RegisterSource(types.SecretSource{
+			ConfigFactory: func() types.SourceConfig {
+				return &fixedConfig{}
+			},
+			SecretGetterFactory: func(cfg types.SourceConfig) (types.SecretGetter, error) {
+				val := cfg.(*fixedConfig).Value
+				return func(context.Context) (string, bool) {
+					if val == "old_value" {
+						close(started)
+						<-release
+					}
+					return val, true
+				}, nil
+			},
+		})
+
+		require.NoError(t, r.LoadSecrets(SecretsConfig{{
+			Name:    "test_secret",
+			Sources: []SecretSource{{TypedConfig: &fixedConfig{Value: "old_value"}}},
+		}}))
+
+		res := make(chan string)
+		go func() {
+			val, _ := r.GetSecret(ctx, "test_secret")
+			res <- val
+		}()
+
+		<-started
+		require.NoError(t, r.ReloadSecrets(SecretsConfig{{
+			Name:    "test_secret",
+			Sources: []SecretSource{{TypedConfig: &fixedConfig{Value: "new_value"}}},
+		}}))
+		close(release)
+
+		require.Equal(t, "old_value", <-res)
+
+		val, ok := r.GetSecret(ctx, "test_secret")
+		require.True(t, ok)
+		require.Equal(t, "new_value", val)
+
+		t.Run("derived", func(t *testing.T) {
+			started = make(chan struct{})
+			release = make(chan struct{})
+
+			require.NoError(t, r.ReloadSecrets(SecretsConfig{
+				{Name: "dsn", Derived: &DerivedConfig{Template: `{{ secret "a" }}-{{ secret "inner" }}`}},
+				{Name: "a", Sources: []SecretSource{{TypedConfig: &fixedConfig{Value: "old_value"}}}},
+				{Name: "inner", Derived: &DerivedConfig{Template: `{{ secret "b" }}`}},
+				{Name: "b", Sources: []SecretSource{{TypedConfig: &fixedConfig{Value: "b_value"}}}},
+			}))
+
+			go func() {
+				val, _ := r.GetSecret(ctx, "dsn")
+				res <- val
+			}()
+
+			// the dependencies removed meanwhile are still resolved, down to the
+			// ones of the derived dependencies
+			<-started
+			require.NoError(t, r.ReloadSecrets(SecretsConfig{
+				{Name: "dsn", Derived: &DerivedConfig{Template: `{{ secret "a" }}`}},
+				{Name: "a", Sources: []SecretSource{{TypedConfig: &fixedConfig{Value: "new_value"}}}},
+			}))
+			close(release)
+
+			require.Equal(t, "old_value-b_value", <-res)
+		})
+	})
+}
@@ -0,0 +1,263 @@
+package pakay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/log"
+	"github.com/jcchavezs/pakay/internal/parser"
+	"github.com/jcchavezs/pakay/internal/secrets"
+	"github.com/jcchavezs/pakay/types"
+)
+
+// Resolution is a resolved secret value.
+type Resolution struct {
+	Value string
+	// Source that resolved the value. For derived secrets its Index is -1 and its
+	// Type is "derived".
+	Source SourceInfo
+	// Metadata reported by the source that resolved the value.
+	Metadata types.SecretMetadata
+	// Defaulted is true when none of the sources returned a value and the value is
+	// the default one declared in the manifest, in which case Source is not set.
+	Defaulted bool
+}
+
+// SourceInfo describes one of the sources of a secret.
+type SourceInfo struct {
+	// Index of the source in the secret declaration.
+	Index       int
+	Type        string
+	Labels      []string
+	LabelValues map[string]string
+	// Description is a redacted representation of the source, e.g. "env: MY_API_TOKEN".
+	Description string
+}
+
+func newSourceInfo(s secrets.Secret, i int) SourceInfo {
+	return SourceInfo{
+		Index:       i,
+		Type:        s.Sources[i].Type,
+		Labels:      s.Getters[i].Labels,
+		LabelValues: s.Getters[i].LabelValues,
+		Description: s.Sources[i].String(),
+	}
+}
+
+type resolveOptions struct {
+	SecretOptions
+	// dryRun skips the interactive sources.
+	dryRun bool
+	// trace records every step of the resolution when set.
+	trace *Trace
+	// profileFilter selects the sources of the profile in use, if any.
+	profileFilter FilterIn
+	// state is the one the secret was looked up in. Profiles and dependencies are
+	// resolved against it so the lookups in flight during a reload finish with the
+	// previous definitions.
+	state *secrets.State
+}
+
+// resolve returns the cached value of the secret or walks its sources, sharing the
+// walk with concurrent lookups of the same secret. Only lookups without a FilterIn
+// or a profile of their own are cached or shared as they may change which sources
+// resolve the value.
+func (r *Registry) resolve(ctx context.Context, s secrets.Secret, opts resolveOptions) (Resolution, error) {
+	if opts.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
+	}
+
+	// only the lookups using the registry profile share the cache
+	ownProfile := opts.Profile != "" && opts.Profile != r.Profile()
+	if opts.Profile == "" {
+		opts.Profile = r.Profile()
+	}
+
+	if opts.Profile != "" {
+		f, ok := opts.state.Profile(opts.Profile)
+		if !ok {
+			return Resolution{}, fmt.Errorf("%w: %q", ErrUnknownProfile, opts.Profile)
+		}
+
+		opts.profileFilter = f
+	}
+
+	// lookups already done don't join the shared walk, they trace the sources they
+	// didn't get to run the same way as the ones ending while walking the sources
+	if opts.FilterIn != nil || ownProfile || opts.trace != nil || opts.dryRun || ctx.Err() != nil {
+		return r.walk(ctx, s, opts)
+	}
+
+	ttl := r.cacheTTLFor(s)
+	if ttl > 0 {
+		if c, ok := r.cache.Get(s.Name); ok {
+			if res, ok := c.resolution(); ok {
+				return res, nil
+			}
+		}
+	}
+
+	gen := r.cache.Generation()
+	res, err := r.secretFlight.Do(ctx, s.Name, func(ctx context.Context) (Resolution, error) {
+		res, err := r.walk(ctx, s, opts)
+		if err == nil && ttl > 0 {
+			if c, ok := r.newCachedResolution(res); ok && !r.cache.Set(s.Name, c, cacheExpiry(res, ttl), gen) {
+				c.destroy()
+			}
+		}
+
+		return res, err
+	})
+	if err != nil && err == ctx.Err() {
+		// the caller stopped waiting for the shared walk
+		return Resolution{}, fmt.Errorf("resolving secret %q: %w", s.Name, err)
+	}
+
+	return res, err
+}
+
+// walk invokes the getters of a secret in order until one of them returns a value,
+// all within the deadline of the secret. It returns ErrNotFound when all the sources
+// came back empty, a *ResolutionError when any of them failed and the context error
+// when it is done before any of them did, unless the secret declares a default.
+func (r *Registry) walk(ctx context.Context, s secrets.Secret, opts resolveOptions) (Resolution, error) {
+	if s.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Deadline)
+		defer cancel()
+	}
+
+	if s.Template != nil {
+		return r.derive(ctx, s, opts)
+	}
+
+	var errs []*SourceError
+
+	filterIn := r.store.Filter(opts.FilterIn)
+	for i, g := range s.Getters {
+		src := s.ManifestEntry.Sources[i]
+		if filterIn != nil {
+			if !filterIn(s.Source(i)) {
+				opts.trace.record(s, i, TraceStep{Outcome: OutcomeFilteredOut, Reason: "excluded by filter"})
+				continue
+			}
+		}
+
+		if opts.profileFilter != nil && !opts.profileFilter(s.Source(i)) {
+			opts.trace.record(s, i, TraceStep{Outcome: OutcomeFilteredOut, Reason: fmt.Sprintf("not in profile %q", opts.Profile)})
+			continue
+		}
+
+		if g.Condition != nil {
+			if err := g.Condition(); err != nil {
+				opts.trace.record(s, i, TraceStep{Outcome: OutcomeSkipped, Reason: conditionFalse + ": " + err.Error()})
+				continue
+			}
+		}
+
+		if opts.dryRun && g.Interactive {
+			opts.trace.record(s, i, TraceStep{Outcome: OutcomeSkipped, Reason: "interactive source in dry run"})
+			continue
+		}
+
+		// there is no point in trying the remaining sources once the context is done
+		if err := ctx.Err(); err != nil {
+			opts.trace.notAttempted(s, i, budgetReason(err))
+			if len(errs) == 0 {
+				return Resolution{}, fmt.Errorf("resolving secret %q: %w", s.Name, err)
+			}
+			break
+		}
+
+		start := time.Now()
+		res, attempts := r.invokeWithPolicy(ctx, g, src.Policy)
+		step := TraceStep{Duration: time.Since(start), Attempts: attempts}
+
+		if res.Err == nil && res.Found && g.Transform != nil {
+			if res.Value, res.Err = g.Transform(res.Value); res.Err != nil {
+				res.Err = fmt.Errorf("%w: %w", ErrTransform, res.Err)
+			}
+		}
+
+		if res.Err == nil && res.Found && s.Validator != nil {
+			if err := s.Validator(res.Value); err != nil {
+				log.FromContext(ctx).WarnContext(ctx, "Source returned an invalid value", "name", s.Name, "source", i, "type", src.Type, "reason", err.Error())
+				step.Outcome, step.Reason = OutcomeInvalid, err.Error()
+				if s.Validate.OnFailure == parser.OnFailureError {
+					step.Err = fmt.Errorf("%w: %w", ErrInvalidValue, err)
+					errs = append(errs, &SourceError{Index: i, Type: src.Type, Err: step.Err})
+				}
+				opts.trace.record(s, i, step)
+				continue
+			}
+		}
+
+		if res.Err != nil {
+			errs = append(errs, &SourceError{Index: i, Type: src.Type, Err: res.Err})
+			step.Outcome, step.Err = errorOutcome(res.Err), res.Err
+			opts.trace.record(s, i, step)
+		} else if res.Found {
+			step.Outcome = OutcomeResolved
+			opts.trace.record(s, i, step)
+			opts.trace.notAttempted(s, i+1, "resolved by a previous source")
+			return Resolution{Value: res.Value, Source: newSourceInfo(s, i), Metadata: res.Metadata}, nil
+		} else {
+			step.Outcome = OutcomeEmpty
+			opts.trace.record(s, i, step)
+		}
+	}
+
+	// the default stands in for the sources that failed as well as for the empty
+	// ones, their errors remain in the trace
+	if s.Default != nil {
+		if len(errs) > 0 {
+			err := &ResolutionError{Name: s.Name, Errors: errs}
+			log.FromContext(ctx).WarnContext(ctx, "Sources failed, using the default value", "name", s.Name, "error", err.Error())
+		}
+
+		opts.trace.defaulted()
+		return Resolution{Value: *s.Default, Defaulted: true}, nil
+	}
+
+	if len(errs) > 0 {
+		return Resolution{}, &ResolutionError{Name: s.Name, Errors: errs}
+	}
+
+	return Resolution{}, fmt.Errorf("%w: %q", ErrNotFound, s.Name)
+}
+
+// invoke calls the getter sharing the result with concurrent invocations of the
+// same source.
+func (r *Registry) invoke(ctx context.Context, g secrets.Getter) types.SecretResult {
+	res, err := r.sourceFlight.Do(ctx, g.Key, func(ctx context.Context) (types.SecretResult, error) {
+		return g.SecretResolver(ctx), nil
+	})
+	if err != nil {
+		return types.SecretResult{Err: err}
+	}
+
+	return res
+}
+
+// conditionFalse is the reason for skipping the sources whose condition doesn't hold.
+const conditionFalse = "condition false"
+
+func budgetReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "deadline exceeded"
+	}
+
+	return "cancelled"
+}
+
+func errorOutcome(err error) TraceOutcome {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return OutcomeTimedOut
+	}
+
+	return OutcomeError
+}
@@ -0,0 +1,194 @@
+package pakay
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/pakay/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentLookupsAreDeduplicated(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		started = make(chan struct{}, 10)
+		release = make(chan struct{})
+	)
+
+	r := NewRegistry()
+	r.RegisterSource(types.SecretSource{
+		ConfigFactory: func() types.SourceConfig {
+			return &fixedConfig{}
+		},
+		SecretResolverFactory: func(cfg types.SourceConfig) (types.SecretResolver, error) {
+			val := cfg.(*fixedConfig).Value
+			return func(ctx context.Context) types.SecretResult {
+				calls.Add(1)
+				started <- struct{}{}
+				select {
+				case <-release:
+					return types.SecretResult{Value: val, Found: true}
+				case <-ctx.Done():
+					return types.SecretResult{Err: ctx.Err()}
+				}
+			}, nil
+		},
+	})
+
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: fixed
+    fixed:
+      value: shared_value
+- name: same_source_secret
+  sources:
+  - type: fixed
+    fixed:
+      value: shared_value
+`)))
+
+	// a waiter giving up doesn't affect the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := r.GetSecretE(ctx, "test_secret")
+		cancelled <- err
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	values := make([]string, 6)
+	for i := range values {
+		name := "test_secret"
+		if i%2 == 1 {
+			name = "same_source_secret"
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i], _ = r.GetSecret(context.Background(), name)
+		}()
+	}
+
+	// give the lookups the chance to join the invocation in flight
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	err := <-cancelled
+	require.ErrorIs(t, err, context.Canceled)
+	require.EqualError(t, err, `resolving secret "test_secret": context canceled`)
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), calls.Load())
+	for _, v := range values {
+		require.Equal(t, "shared_value", v)
+	}
+}
+
+func TestSecretDeadline(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: slow_secret
+  deadline: 100ms
+  sources:
+  - type: bash
+    bash:
+      command: exec sleep 5
+  - type: bash
+    bash:
+      command: exec sleep 5
+  - type: static
+    static:
+      value: test_value
+- name: unbounded_secret
+  sources:
+  - type: bash
+    bash:
+      command: exec sleep 5
+  - type: static
+    static:
+      value: test_value
+`)))
+
+	t.Run("manifest deadline", func(t *testing.T) {
+		start := time.Now()
+		tr, err := r.Explain(context.Background(), "slow_secret", ExplainOptions{})
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), time.Second)
+
+		require.ErrorIs(t, tr.Err, context.DeadlineExceeded)
+		require.Len(t, tr.Steps, 3)
+		require.Equal(t, OutcomeTimedOut, tr.Steps[0].Outcome)
+		require.Equal(t, OutcomeNotAttempted, tr.Steps[1].Outcome)
+		require.Equal(t, "deadline exceeded", tr.Steps[1].Reason)
+		require.Equal(t, OutcomeNotAttempted, tr.Steps[2].Outcome)
+	})
+
+	t.Run("lookup deadline", func(t *testing.T) {
+		start := time.Now()
+		_, err := r.GetSecretEWithOptions(context.Background(), "unbounded_secret", SecretOptions{Deadline: 100 * time.Millisecond})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), time.Second)
+
+		require.ErrorContains(t, err, `resolving secret "unbounded_secret"`)
+
+		tr, err := r.Explain(context.Background(), "unbounded_secret", ExplainOptions{Deadline: 100 * time.Millisecond})
+		require.NoError(t, err)
+		require.ErrorIs(t, tr.Err, context.DeadlineExceeded)
+		require.Len(t, tr.Steps, 2)
+		require.Equal(t, OutcomeTimedOut, tr.Steps[0].Outcome)
+		require.Equal(t, OutcomeNotAttempted, tr.Steps[1].Outcome)
+		require.Equal(t, "deadline exceeded", tr.Steps[1].Reason)
+	})
+}
+
+func TestSourcesSeeTheDeadline(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+
+	r := NewRegistry()
+	r.RegisterSource(types.SecretSource{
+		ConfigFactory: func() types.SourceConfig {
+			return &fixedConfig{}
+		},
+		SecretResolverFactory: func(cfg types.SourceConfig) (types.SecretResolver, error) {
+			val := cfg.(*fixedConfig).Value
+			return func(ctx context.Context) types.SecretResult {
+				deadline, _ := ctx.Deadline()
+				deadlines <- deadline
+				return types.SecretResult{Value: val, Found: true}
+			}, nil
+		},
+	})
+
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: fixed
+    fixed:
+      value: test_value
+- name: timed_secret
+  sources:
+  - type: fixed
+    policy:
+      timeout: 1m
+    fixed:
+      value: other_value
+`)))
+
+	start := time.Now()
+	_, err := r.GetSecretEWithOptions(context.Background(), "test_secret", SecretOptions{Deadline: time.Hour})
+	require.NoError(t, err)
+	require.WithinDuration(t, start.Add(time.Hour), <-deadlines, time.Minute)
+
+	start = time.Now()
+	_, err = r.GetSecretE(context.Background(), "timed_secret")
+	require.NoError(t, err)
+	require.WithinDuration(t, start.Add(time.Minute), <-deadlines, 10*time.Second)
+}
@@ -2,14 +2,9 @@ package pakay
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"log/slog"
-	"sync"
+	"time"
 
-	"github.com/jcchavezs/pakay/internal/log"
-	"github.com/jcchavezs/pakay/internal/parser"
-	"github.com/jcchavezs/pakay/internal/secrets"
 	"github.com/jcchavezs/pakay/internal/sources"
 )
 
@@ -20,83 +15,85 @@ var RegisterSource = sources.Register
 
 type LoadConfigOptions struct {
 	Variables map[string]string
+	// Overlays are manifest documents applied in order on top of the manifest to
+	// add, replace, remove or reorder the sources of its secrets, keyed by the name
+	// of the secret and the type of the source, e.g.
+	//
+	//	- name: api_token
+	//	  sources:
+	//	  - type: env          # replaces the env source or appends it
+	//	    env:
+	//	      key: CI_API_TOKEN
+	//	  - type: stdin        # removes the stdin source
+	//	    $patch: delete
+	//	  order: [env, 1password]
+	//
+	// Overlays can't declare secrets, it is an error to target one that doesn't
+	// exist in the manifest.
+	Overlays [][]byte
 	LoadOptions
 }
 
+// ProfileEnvVar is the environment variable selecting the profile of a registry
+// when none is set in LoadOptions.
+const ProfileEnvVar = "PAKAY_PROFILE"
+
+// SelectorEnvVar is the environment variable holding the selector of a registry
+// when none is set in LoadOptions.
+const SelectorEnvVar = "PAKAY_SELECTOR"
+
 type LoadOptions struct {
 	LogHandler slog.Handler
+	// Cache configures the in-process caching of resolved secrets. Secrets can
+	// override it in the manifest. Caching is disabled by default.
+	Cache CacheOptions
+	// SecureMemory holds the cached values and the ones returned by GetSecretValue
+	// in locked memory that is wiped once evicted or destroyed, and marks the
+	// process as non-dumpable. Memory is only locked on Linux. Once enabled it
+	// stays enabled for the registry.
+	//
+	// Every lookup still goes through a plaintext string on the heap: the value
+	// returned by the source and, on cache hits, the copy taken out of the locked
+	// cache. Those copies are left to the garbage collector and can't be wiped, so
+	// secure memory narrows the exposure of long lived values without removing it.
+	// Values returned by GetSecretValue hold locked memory until destroyed or
+	// collected, destroy them as soon as they are no longer needed.
+	SecureMemory bool
+	// Profile selects the sources of one of the profiles declared in the manifest
+	// for all the lookups. Defaults to the PAKAY_PROFILE environment variable, which
+	// is ignored when the manifest doesn't declare that profile.
+	Profile string
+	// Selector is a label selector applied to all the lookups, see ParseSelector.
+	// Defaults to the PAKAY_SELECTOR environment variable.
+	Selector string
+	// Naming derives the configuration of the sources declared without one from the
+	// name of their secret. The rules declared in the `naming` section of the
+	// manifest take precedence.
+	Naming NamingRules
 }
 
-func LoadSecrets(config SecretsConfig) error {
-	return loadSecretsFromManifestEntries(config.toManifestEntries(), LoadOptions{})
+type CacheOptions struct {
+	// TTL of the cached values, zero disables caching.
+	TTL time.Duration
 }
 
-func LoadSecretsWithOptions(config SecretsConfig, opts LoadOptions) error {
-	return loadSecretsFromManifestEntries(config.toManifestEntries(), opts)
+func LoadSecrets(config SecretsConfig) error {
+	return defaultRegistry.LoadSecrets(config)
 }
 
-func loadSecretsFromManifestEntries(cfg []parser.ManifestEntry, opts LoadOptions) error {
-	for _, c := range cfg {
-		if _, ok := secrets.All[c.Name]; ok {
-			return fmt.Errorf("duplicated declaration for %q", c.Name)
-		}
-
-		s := secrets.Secret{
-			ManifestEntry: c,
-			Getters:       make([]secrets.Getter, 0, len(c.Sources)),
-		}
-
-		for _, src := range c.Sources {
-			p, ok := sources.Get(src.Type)
-			if !ok {
-				return fmt.Errorf("unknown source: %s", src.Type)
-			}
-
-			g, err := p.SecretGetterFactory(src.Config)
-			if err != nil {
-				return fmt.Errorf("building secret getter for %s: %w", p.ConfigFactory().Type(), err)
-			}
-
-			sMutex.Lock()
-			s.Getters = append(s.Getters, secrets.Getter{
-				Labels:       src.Labels,
-				SecretGetter: g,
-			})
-			sMutex.Unlock()
-		}
-
-		secrets.All[c.Name] = s
-	}
-
-	if opts.LogHandler == nil {
-		log.SetHandler(log.DiscardHandler)
-	} else {
-		log.SetHandler(opts.LogHandler)
-	}
-
-	sMutex.Lock()
-	secrets.Loaded = true
-	sMutex.Unlock()
-
-	return nil
+func LoadSecretsWithOptions(config SecretsConfig, opts LoadOptions) error {
+	return defaultRegistry.LoadSecretsWithOptions(config, opts)
 }
 
 // LoadSecretsConfig loads secrets from a YAML manifest provided as a byte slice.
 // The manifest should contain a list of secrets with their names, descriptions, and sources.
 // Each source should specify a type and its configuration.
 func LoadSecretsConfig(config []byte) error {
-	return LoadSecretsConfigWithOptions(config, LoadConfigOptions{})
+	return defaultRegistry.LoadSecretsConfig(config)
 }
 
-var sMutex sync.RWMutex
-
 func LoadSecretsConfigWithOptions(config []byte, opts LoadConfigOptions) error {
-	cfg, err := parser.ParseManifest(config, opts.Variables)
-	if err != nil {
-		return fmt.Errorf("parsing manifest: %w", err)
-	}
-
-	return loadSecretsFromManifestEntries(cfg, opts.LoadOptions)
+	return defaultRegistry.LoadSecretsConfigWithOptions(config, opts)
 }
 
 // GetSecret retrieves the value of a secret by its name.
@@ -105,67 +102,129 @@ func LoadSecretsConfigWithOptions(config []byte, opts LoadConfigOptions) error {
 // The function will try each getter associated with the secret until it finds a valid value.
 // If no getter returns a valid value, it will return an empty string and false.
 func GetSecret(ctx context.Context, name string) (string, bool) {
-	return GetSecretWithOptions(ctx, name, SecretOptions{})
+	return defaultRegistry.GetSecret(ctx, name)
 }
 
-type SecretOptions struct {
-	FilterIn FilterIn
+// GetSecretE retrieves the value of a secret by its name, returning an error that
+// tells why it couldn't be retrieved:
+//   - ErrNotLoaded if no manifest has been loaded yet.
+//   - ErrUnknownSecret if the secret isn't declared in the manifest.
+//   - ErrNotFound if none of the sources returned a value.
+//   - *ResolutionError if any of the sources failed.
+func GetSecretE(ctx context.Context, name string) (string, error) {
+	return defaultRegistry.GetSecretE(ctx, name)
 }
 
-func GetSecretWithOptions(ctx context.Context, name string, opts SecretOptions) (string, bool) {
-	if !checkSecretsAreLoaded() {
-		log.Logger.Error("Secrets haven't been loaded yet")
-		return "", false
-	}
-
-	s, ok := secrets.All[name]
-	if !ok {
-		log.Logger.Error("Unknown secret", "name", name)
-		return "", false
-	}
+func GetSecretEWithOptions(ctx context.Context, name string, opts SecretOptions) (string, error) {
+	return defaultRegistry.GetSecretEWithOptions(ctx, name, opts)
+}
 
-	for i, g := range s.Getters {
-		if opts.FilterIn != nil {
-			if !opts.FilterIn(Source{Type: s.ManifestEntry.Sources[i].Type, Labels: g.Labels}) {
-				continue
-			}
-		}
+// ResolveSecret retrieves a secret along with the metadata reported by the source
+// that resolved it, e.g. its version or expiry. Errors are the same as in GetSecretE.
+func ResolveSecret(ctx context.Context, name string) (Resolution, error) {
+	return defaultRegistry.ResolveSecret(ctx, name)
+}
 
-		if val, ok := g.SecretGetter(ctx); ok {
-			return val, true
-		}
-	}
+func ResolveSecretWithOptions(ctx context.Context, name string, opts SecretOptions) (Resolution, error) {
+	return defaultRegistry.ResolveSecretWithOptions(ctx, name, opts)
+}
 
-	return "", false
+type SecretOptions struct {
+	FilterIn FilterIn
+	// Selector is a label selector applied on top of FilterIn, see ParseSelector.
+	Selector string
+	// Profile selects the sources of one of the profiles declared in the manifest
+	// instead of the registry profile.
+	Profile string
+	// Deadline caps the time spent resolving the secret across all its sources,
+	// on top of the deadline declared in the manifest.
+	Deadline time.Duration
 }
 
-func checkSecretsAreLoaded() bool {
-	sMutex.RLock()
-	defer sMutex.RUnlock()
-	return secrets.Loaded
+func GetSecretWithOptions(ctx context.Context, name string, opts SecretOptions) (string, bool) {
+	return defaultRegistry.GetSecretWithOptions(ctx, name, opts)
 }
 
 func AssertSecrets(ctx context.Context) ([]string, error) {
-	return AssertSecretsWithOptions(ctx, AssertOptions{})
+	return defaultRegistry.AssertSecrets(ctx)
 }
 
 type AssertOptions struct {
 	FilterIn FilterIn
+	// Selector is a label selector applied on top of FilterIn, see ParseSelector.
+	Selector string
+	// Profile selects the sources of one of the profiles declared in the manifest
+	// instead of the registry profile.
+	Profile string
+	// Concurrency is the maximum number of secrets resolved at the same time.
+	// Secrets are resolved one at a time by default.
+	Concurrency int
+	// Timeout for asserting all the secrets. Secrets not resolved in time are
+	// reported as timed out. Zero means no timeout.
+	Timeout time.Duration
 }
 
 // AssertSecrets asserts the availability of the loaded secrets.
 // It is useful to check the secrets before running the command.
 func AssertSecretsWithOptions(ctx context.Context, opts AssertOptions) ([]string, error) {
-	if !checkSecretsAreLoaded() {
-		return nil, errors.New("secrets haven't been loaded yet")
-	}
-
-	missing := []string{}
-	for name := range secrets.All {
-		if _, ok := GetSecretWithOptions(ctx, name, (SecretOptions)(opts)); !ok {
-			missing = append(missing, name)
-		}
-	}
-
-	return missing, nil
+	return defaultRegistry.AssertSecretsWithOptions(ctx, opts)
+}
+
+// AssertSecretsReport asserts the availability of the loaded secrets reporting the
+// status of each of them in manifest order.
+func AssertSecretsReport(ctx context.Context, opts AssertOptions) (AssertReport, error) {
+	return defaultRegistry.AssertSecretsReport(ctx, opts)
+}
+
+// Reload replaces the secrets in the default registry with the ones in the manifest.
+// See Registry.Reload.
+func Reload(config []byte) error {
+	return defaultRegistry.Reload(config)
+}
+
+func ReloadWithOptions(config []byte, opts LoadConfigOptions) error {
+	return defaultRegistry.ReloadWithOptions(config, opts)
+}
+
+// Unload removes all the secrets from the default registry.
+func Unload() {
+	defaultRegistry.Unload()
+}
+
+// Subscribe registers a function to be called every time the secrets in the default
+// registry change. See Registry.Subscribe.
+func Subscribe(fn func(ChangeEvent)) (unsubscribe func()) {
+	return defaultRegistry.Subscribe(fn)
+}
+
+// WatchSecretsConfig loads the manifest at the given path into the default registry
+// and reloads it on every change. See Registry.WatchSecretsConfig.
+func WatchSecretsConfig(ctx context.Context, path string, opts WatchOptions) error {
+	return defaultRegistry.WatchSecretsConfig(ctx, path, opts)
+}
+
+// Explain resolves a secret from the default registry recording what happened with
+// each of its sources. See Registry.Explain.
+func Explain(ctx context.Context, name string, opts ExplainOptions) (Trace, error) {
+	return defaultRegistry.Explain(ctx, name, opts)
+}
+
+// Invalidate evicts the cached value of a secret in the default registry.
+func Invalidate(name string) {
+	defaultRegistry.Invalidate(name)
+}
+
+// InvalidateAll evicts all the cached values in the default registry.
+func InvalidateAll() {
+	defaultRegistry.InvalidateAll()
+}
+
+// GetSecretValue retrieves a secret as a Value, which redacts itself when printed,
+// logged or serialized. Errors are the same as in GetSecretE.
+func GetSecretValue(ctx context.Context, name string) (Value, error) {
+	return defaultRegistry.GetSecretValue(ctx, name)
+}
+
+func GetSecretValueWithOptions(ctx context.Context, name string, opts SecretOptions) (Value, error) {
+	return defaultRegistry.GetSecretValueWithOptions(ctx, name, opts)
 }
@@ -2,11 +2,12 @@ package pakay
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"testing"
 
-	"github.com/jcchavezs/pakay/internal/secrets"
 	"github.com/jcchavezs/pakay/internal/sources/env"
+	"github.com/jcchavezs/pakay/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,8 +24,7 @@ func (rh *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return rh }
 func (rh *recordHandler) WithGroup(name string) slog.Handler       { return rh }
 
 func unloadSecrets() {
-	secrets.All = make(map[string]secrets.Secret)
-	secrets.Loaded = false
+	Unload()
 }
 
 func TestLoadSecretsConfig(t *testing.T) {
@@ -134,6 +134,30 @@ func TestLoadSecretsConfig(t *testing.T) {
 		require.Contains(t, err.Error(), "unknown source: unknown_source")
 	})
 
+	t.Run("failed load leaves no secrets behind", func(t *testing.T) {
+		t.Cleanup(unloadSecrets)
+
+		config := `---
+- name: test_secret_1
+  sources:
+  - type: static
+    static:
+      value: test_value
+- name: test_secret_2
+  sources:
+  - type: unknown_source
+`
+
+		err := LoadSecretsConfig([]byte(config))
+		require.Error(t, err)
+
+		_, ok := GetSecret(context.Background(), "test_secret_1")
+		require.False(t, ok)
+
+		_, err = AssertSecrets(context.Background())
+		require.ErrorContains(t, err, "secrets haven't been loaded yet")
+	})
+
 	t.Run("returns error for duplicated secret", func(t *testing.T) {
 		t.Cleanup(unloadSecrets)
 
@@ -155,3 +179,96 @@ func TestLoadSecretsConfig(t *testing.T) {
 		require.Contains(t, err.Error(), "duplicated declaration for \"test_secret\"")
 	})
 }
+
+func TestGetSecretE(t *testing.T) {
+	t.Cleanup(unloadSecrets)
+
+	_, err := GetSecretE(context.Background(), "test_secret")
+	require.ErrorIs(t, err, ErrNotLoaded)
+
+	config := `---
+- name: test_secret
+  sources:
+  - type: env
+    env:
+      key: TEST_ENV_VAR_E
+`
+	require.NoError(t, LoadSecretsConfig([]byte(config)))
+
+	_, err = GetSecretE(context.Background(), "unknown_secret")
+	require.ErrorIs(t, err, ErrUnknownSecret)
+	require.ErrorContains(t, err, `"unknown_secret"`)
+
+	_, err = GetSecretE(context.Background(), "test_secret")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = GetSecretE(ctx, "test_secret")
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotErrorIs(t, err, ErrNotFound)
+
+	var rErr *ResolutionError
+	require.False(t, errors.As(err, &rErr), "no source failed")
+	require.EqualError(t, err, `resolving secret "test_secret": context canceled`)
+
+	t.Setenv("TEST_ENV_VAR_E", "test_value")
+	val, err := GetSecretE(context.Background(), "test_secret")
+	require.NoError(t, err)
+	require.Equal(t, "test_value", val)
+}
+
+func TestResolveSecret(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterSource(types.SecretSource{
+		ConfigFactory: func() types.SourceConfig {
+			return &fixedConfig{}
+		},
+		SecretResolverFactory: func(cfg types.SourceConfig) (types.SecretResolver, error) {
+			val := cfg.(*fixedConfig).Value
+			return func(context.Context) types.SecretResult {
+				if val == "failing" {
+					return types.SecretResult{Err: errors.New("vault is sealed")}
+				}
+
+				return types.SecretResult{Value: val, Found: true, Metadata: types.SecretMetadata{Version: "3"}}
+			}, nil
+		},
+	})
+
+	config := `---
+- name: test_secret
+  sources:
+  - type: fixed
+    fixed:
+      value: failing
+  - type: fixed
+    fixed:
+      value: test_value
+- name: failing_secret
+  sources:
+  - type: fixed
+    fixed:
+      value: failing
+  - type: env
+    env:
+      key: TEST_RESOLVE_MISSING_VAR
+`
+	require.NoError(t, r.LoadSecretsConfig([]byte(config)))
+
+	res, err := r.ResolveSecret(context.Background(), "test_secret")
+	require.NoError(t, err)
+	require.Equal(t, "test_value", res.Value)
+	require.Equal(t, "3", res.Metadata.Version)
+	require.Equal(t, SourceInfo{Index: 1, Type: "fixed", Description: "fixed: test_value"}, res.Source)
+
+	_, err = r.ResolveSecret(context.Background(), "failing_secret")
+	require.NotErrorIs(t, err, ErrNotFound)
+
+	var rErr *ResolutionError
+	require.ErrorAs(t, err, &rErr)
+	require.Len(t, rErr.Errors, 1)
+	require.Equal(t, 0, rErr.Errors[0].Index)
+	require.ErrorContains(t, rErr.Errors[0], "vault is sealed")
+}
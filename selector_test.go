@@ -0,0 +1,116 @@
+package pakay
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const selectorManifest = `---
+- name: api_token
+  sources:
+  - type: stdin
+    stdin:
+      prompt: Please insert the API token
+  - type: static
+    labels: [env=ci]
+    static:
+      value: ci_value
+  - type: static
+    labels:
+      env: prod
+    static:
+      value: prod_value
+`
+
+func TestSelector(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("per lookup", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(selectorManifest)))
+
+		res, err := r.ResolveSecretWithOptions(ctx, "api_token", SecretOptions{Selector: "env in (ci,prod),!interactive"})
+		require.NoError(t, err)
+		require.Equal(t, "ci_value", res.Value)
+		require.Equal(t, map[string]string{"env": "ci"}, res.Source.LabelValues)
+
+		// selectors are applied on top of FilterIn
+		val, err := r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{
+			Selector: "!interactive",
+			FilterIn: func(s Source) bool { return s.LabelValues["env"] == "prod" },
+		})
+		require.NoError(t, err)
+		require.Equal(t, "prod_value", val)
+
+		// key=value items remain plain labels for the filters matching them
+		val, err = r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{
+			FilterIn: func(s Source) bool { return slices.Contains(s.Labels, "env=ci") },
+		})
+		require.NoError(t, err)
+		require.Equal(t, "ci_value", val)
+
+		_, err = r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{Selector: "env in (ci"})
+		require.ErrorIs(t, err, ErrInvalidSelector)
+
+		trace, err := r.Explain(ctx, "api_token", ExplainOptions{Selector: "env=prod"})
+		require.NoError(t, err)
+		require.Equal(t, OutcomeFilteredOut, trace.Steps[0].Outcome)
+		require.Equal(t, OutcomeFilteredOut, trace.Steps[1].Outcome)
+		require.Equal(t, OutcomeResolved, trace.Steps[2].Outcome)
+
+		_, err = r.Explain(ctx, "api_token", ExplainOptions{Selector: "!"})
+		require.ErrorIs(t, err, ErrInvalidSelector)
+
+		report, err := r.AssertSecretsReport(ctx, AssertOptions{Selector: "env=prod"})
+		require.NoError(t, err)
+		require.Equal(t, StatusFound, report[0].Status)
+		require.Equal(t, 2, report[0].Source.Index)
+
+		missing, err := r.AssertSecretsWithOptions(ctx, AssertOptions{Selector: "env=staging"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"api_token"}, missing)
+
+		_, err = r.AssertSecretsReport(ctx, AssertOptions{Selector: "env=(ci)"})
+		require.ErrorIs(t, err, ErrInvalidSelector)
+	})
+
+	t.Run("from load options", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(selectorManifest), LoadConfigOptions{
+			LoadOptions: LoadOptions{Selector: "env=prod"},
+		}))
+		require.Equal(t, "env=prod", r.Selector())
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "prod_value", val)
+
+		// lookups can't select sources excluded by the registry selector
+		_, err = r.GetSecretEWithOptions(ctx, "api_token", SecretOptions{Selector: "env=ci"})
+		require.ErrorIs(t, err, ErrNotFound)
+
+		err = r.LoadSecretsConfigWithOptions([]byte("- name: other\n  sources:\n  - type: static\n    static:\n      value: v\n"), LoadConfigOptions{
+			LoadOptions: LoadOptions{Selector: "env in ("},
+		})
+		require.ErrorIs(t, err, ErrInvalidSelector)
+		require.Equal(t, "env=prod", r.Selector())
+
+		_, err = r.GetSecretE(ctx, "other")
+		require.ErrorIs(t, err, ErrUnknownSecret)
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv(SelectorEnvVar, "env=ci")
+
+		r := NewRegistry()
+		require.NoError(t, r.LoadSecretsConfig([]byte(selectorManifest)))
+		require.Equal(t, "env=ci", r.Selector())
+
+		val, err := r.GetSecretE(ctx, "api_token")
+		require.NoError(t, err)
+		require.Equal(t, "ci_value", val)
+	})
+}
@@ -0,0 +1,61 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransform(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: db_password
+  sources:
+  - type: bash
+    transform:
+    - json_path: $.data.password
+    - base64_decode
+    bash:
+      command: 'echo "{\"data\":{\"password\":\"czNjcjN0\"}}"'
+- name: api_token
+  sources:
+  - type: static
+    transform:
+    - json_path: $.token
+    static:
+      value: not json
+  - type: static
+    static:
+      value: fallback
+`)))
+
+	val, err := r.GetSecretE(ctx, "db_password")
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", val)
+
+	// a failed transformation fails the source
+	val, err = r.GetSecretE(ctx, "api_token")
+	require.NoError(t, err)
+	require.Equal(t, "fallback", val)
+
+	trace, err := r.Explain(ctx, "api_token", ExplainOptions{})
+	require.NoError(t, err)
+	require.Equal(t, OutcomeError, trace.Steps[0].Outcome)
+	require.ErrorIs(t, trace.Steps[0].Err, ErrTransform)
+	require.NotContains(t, trace.Steps[0].Err.Error(), "not json")
+}
+
+func TestTransformOnLoad(t *testing.T) {
+	err := NewRegistry().LoadSecretsConfig([]byte(`---
+- name: api_token
+  sources:
+  - type: env
+    transform:
+    - rot13
+    env:
+      key: API_TOKEN
+`))
+	require.ErrorContains(t, err, `invalid transform for env source of "api_token": step #0 (rot13): unknown step`)
+}
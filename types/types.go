@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"fmt"
+	"time"
 
 	internaltypes "github.com/jcchavezs/pakay/internal/types"
 )
@@ -11,10 +12,39 @@ type (
 	// SecretGetter gets a given secret
 	SecretGetter func(ctx context.Context) (string, bool)
 
+	// SecretResolver gets a given secret reporting failures and metadata about the
+	// value. It supersedes SecretGetter.
+	SecretResolver func(ctx context.Context) SecretResult
+
+	// SecretResult is the outcome of resolving a secret from a source
+	SecretResult struct {
+		Value string
+		// Found is true when the source returned a value.
+		Found bool
+		// Err is set when the source failed, as opposed to not having a value.
+		Err      error
+		Metadata SecretMetadata
+	}
+
+	// SecretMetadata describes a secret value as reported by its source. All the
+	// fields are optional.
+	SecretMetadata struct {
+		Version   string
+		CreatedAt time.Time
+		ExpiresAt time.Time
+		// Reference locates the secret in the source, e.g. a 1Password reference.
+		Reference string
+	}
+
 	// SecretSource is a source for a given secret
 	SecretSource struct {
 		ConfigFactory       func() SourceConfig
 		SecretGetterFactory func(cfg SourceConfig) (SecretGetter, error)
+		// SecretResolverFactory takes precedence over SecretGetterFactory when set.
+		SecretResolverFactory func(cfg SourceConfig) (SecretResolver, error)
+		// Interactive sources require user input, e.g. a prompt, and are skipped
+		// in dry runs.
+		Interactive bool
 	}
 
 	// SourceConfig is the config for a source of a given secret
@@ -24,3 +54,44 @@ type (
 		Type() string
 	}
 )
+
+// NewSecretResolver builds the resolver for the given config, adapting the
+// SecretGetter of sources that don't provide a SecretResolverFactory.
+func (s SecretSource) NewSecretResolver(cfg SourceConfig) (SecretResolver, error) {
+	if s.SecretResolverFactory != nil {
+		return s.SecretResolverFactory(cfg)
+	}
+
+	g, err := s.SecretGetterFactory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Resolver(), nil
+}
+
+// Resolver adapts the getter to the SecretResolver contract. As a getter can't
+// report failures, an empty value returned once the context is done is reported
+// as the context error.
+func (g SecretGetter) Resolver() SecretResolver {
+	return func(ctx context.Context) SecretResult {
+		val, ok := g(ctx)
+		if !ok {
+			return SecretResult{Err: ctx.Err()}
+		}
+
+		return SecretResult{Value: val, Found: true}
+	}
+}
+
+// Getter adapts the resolver to the SecretGetter contract, dropping errors and metadata.
+func (r SecretResolver) Getter() SecretGetter {
+	return func(ctx context.Context) (string, bool) {
+		res := r(ctx)
+		if res.Err != nil || !res.Found {
+			return "", false
+		}
+
+		return res.Value, true
+	}
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	SourceConfig
+}
+
+func TestSecretSource_NewSecretResolver(t *testing.T) {
+	t.Run("adapts the getter", func(t *testing.T) {
+		s := SecretSource{
+			SecretGetterFactory: func(SourceConfig) (SecretGetter, error) {
+				return func(context.Context) (string, bool) { return "value", true }, nil
+			},
+		}
+
+		r, err := s.NewSecretResolver(testConfig{})
+		require.NoError(t, err)
+		require.Equal(t, SecretResult{Value: "value", Found: true}, r(context.Background()))
+	})
+
+	t.Run("prefers the resolver", func(t *testing.T) {
+		s := SecretSource{
+			SecretGetterFactory: func(SourceConfig) (SecretGetter, error) {
+				return nil, errors.New("should not be called")
+			},
+			SecretResolverFactory: func(SourceConfig) (SecretResolver, error) {
+				return func(context.Context) SecretResult {
+					return SecretResult{Value: "value", Found: true, Metadata: SecretMetadata{Version: "2"}}
+				}, nil
+			},
+		}
+
+		r, err := s.NewSecretResolver(testConfig{})
+		require.NoError(t, err)
+		require.Equal(t, "2", r(context.Background()).Metadata.Version)
+	})
+}
+
+func TestSecretGetter_Resolver(t *testing.T) {
+	g := SecretGetter(func(context.Context) (string, bool) { return "", false })
+
+	require.Equal(t, SecretResult{}, g.Resolver()(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, g.Resolver()(ctx).Err, context.Canceled)
+}
+
+func TestSecretResolver_Getter(t *testing.T) {
+	r := SecretResolver(func(context.Context) SecretResult {
+		return SecretResult{Value: "value", Found: true, Err: errors.New("failed")}
+	})
+
+	val, ok := r.Getter()(context.Background())
+	require.False(t, ok)
+	require.Empty(t, val)
+}
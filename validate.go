@@ -0,0 +1,36 @@
+package pakay
+
+import (
+	"github.com/jcchavezs/pakay/internal/parser"
+)
+
+// ValidationRules are checked against the values returned by the sources of a secret.
+// A source returning an invalid value is treated as if it returned none unless
+// OnFailure is "error".
+type ValidationRules struct {
+	// Regex the value must match.
+	Regex     string
+	MinLength int
+	MaxLength int
+	// Charset is one of alphanumeric, hex, base64, base64url, ascii or printable.
+	Charset string
+	// Format is one of url, uuid, base64, jwt, pem_certificate, pem_private_key or json.
+	Format string
+	// OnFailure is either "not_found", the default, or "error".
+	OnFailure string
+}
+
+func (v *ValidationRules) toParser() *parser.ValidationRules {
+	if v == nil {
+		return nil
+	}
+
+	return &parser.ValidationRules{
+		Regex:     v.Regex,
+		MinLength: v.MinLength,
+		MaxLength: v.MaxLength,
+		Charset:   v.Charset,
+		Format:    v.Format,
+		OnFailure: v.OnFailure,
+	}
+}
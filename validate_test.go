@@ -0,0 +1,77 @@
+package pakay
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  validate:
+    regex: ^tok_[a-z0-9]+$
+  sources:
+  - type: static
+    static:
+      value: "tok_abc\n"
+  - type: static
+    static:
+      value: tok_abc
+- name: api_url
+  validate:
+    format: url
+    on_failure: error
+  sources:
+  - type: static
+    static:
+      value: not a url
+`)))
+
+	val, err := r.GetSecretE(ctx, "api_token")
+	require.NoError(t, err)
+	require.Equal(t, "tok_abc", val)
+
+	trace, err := r.Explain(ctx, "api_token", ExplainOptions{})
+	require.NoError(t, err)
+	require.Equal(t, OutcomeInvalid, trace.Steps[0].Outcome)
+	require.Equal(t, `does not match "^tok_[a-z0-9]+$"`, trace.Steps[0].Reason)
+	require.Equal(t, 1, trace.Source.Index)
+
+	_, err = r.GetSecretE(ctx, "api_url")
+	require.ErrorIs(t, err, ErrInvalidValue)
+	require.NotContains(t, err.Error(), "not a url")
+
+	report, err := r.AssertSecretsReport(ctx, AssertOptions{})
+	require.NoError(t, err)
+	require.Equal(t, StatusErrored, report[1].Status)
+}
+
+func TestValidationOnLoad(t *testing.T) {
+	r := NewRegistry()
+	err := r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  default: short
+  validate:
+    min_length: 10
+  sources:
+  - type: env
+    env:
+      key: API_TOKEN
+`))
+	require.ErrorContains(t, err, `invalid default value for "api_token"`)
+
+	err = r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  validate:
+    format: xml
+  sources:
+  - type: env
+    env:
+      key: API_TOKEN
+`))
+	require.ErrorContains(t, err, "unknown format: xml")
+}
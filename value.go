@@ -0,0 +1,141 @@
+package pakay
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/jcchavezs/pakay/internal/securemem"
+)
+
+const redacted = "[REDACTED]"
+
+// Value is a secret value that redacts itself when printed, logged or serialized.
+// The plaintext is only available through Reveal and Bytes.
+//
+// Values returned by a registry loaded with SecureMemory hold the plaintext in
+// locked memory until Destroy is called.
+type Value struct {
+	plaintext string
+	buf       *securemem.Buffer
+}
+
+var (
+	_ fmt.Stringer           = Value{}
+	_ fmt.GoStringer         = Value{}
+	_ fmt.Formatter          = Value{}
+	_ slog.LogValuer         = Value{}
+	_ json.Marshaler         = Value{}
+	_ encoding.TextMarshaler = Value{}
+)
+
+// NewValue wraps the plaintext in a Value.
+func NewValue(plaintext string) Value {
+	return Value{plaintext: plaintext}
+}
+
+// newSecureValue copies the plaintext into secure memory.
+func newSecureValue(plaintext string) (Value, error) {
+	buf, err := securemem.New([]byte(plaintext))
+	if err != nil {
+		return Value{}, fmt.Errorf("allocating secure memory: %w", err)
+	}
+
+	return Value{buf: buf}, nil
+}
+
+// Reveal returns the plaintext of the secret, or an empty string once destroyed.
+func (v Value) Reveal() string {
+	if v.buf == nil {
+		return v.plaintext
+	}
+
+	return string(v.Bytes())
+}
+
+// Bytes returns a copy of the plaintext of the secret, or nil once destroyed.
+// Callers holding sensitive values should wipe the copy once done with it.
+func (v Value) Bytes() []byte {
+	if v.buf == nil {
+		return []byte(v.plaintext)
+	}
+
+	b, err := v.buf.Copy()
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+// IsEmpty returns whether the value is empty.
+func (v Value) IsEmpty() bool {
+	if v.buf == nil {
+		return len(v.plaintext) == 0
+	}
+
+	b := v.Bytes()
+	defer securemem.Wipe(b)
+	return len(b) == 0
+}
+
+// Destroy wipes the plaintext held in secure memory. Copies of the value share
+// the same memory so they are destroyed too. It is a no-op for values not held
+// in secure memory.
+func (v Value) Destroy() {
+	if v.buf != nil {
+		_ = v.buf.Destroy()
+	}
+}
+
+func (Value) String() string {
+	return redacted
+}
+
+func (Value) GoString() string {
+	return "pakay.Value(" + redacted + ")"
+}
+
+// Format redacts the value for every verb, including %v, %s, %q, %x and %#v.
+func (v Value) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		_, _ = fmt.Fprint(f, v.GoString())
+	case verb == 'q':
+		_, _ = fmt.Fprintf(f, "%q", redacted)
+	default:
+		_, _ = fmt.Fprint(f, redacted)
+	}
+}
+
+func (Value) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+func (Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+func (Value) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// GetSecretValue retrieves a secret as a Value. See GetSecretValue.
+func (r *Registry) GetSecretValue(ctx context.Context, name string) (Value, error) {
+	return r.GetSecretValueWithOptions(ctx, name, SecretOptions{})
+}
+
+func (r *Registry) GetSecretValueWithOptions(ctx context.Context, name string, opts SecretOptions) (Value, error) {
+	res, err := r.ResolveSecretWithOptions(ctx, name, opts)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if r.secureMemory() {
+		return newSecureValue(res.Value)
+	}
+
+	return NewValue(res.Value), nil
+}
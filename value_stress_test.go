@@ -0,0 +1,37 @@
+//go:build stress
+
+package pakay
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSecureMemoryRepeatedLookups maps more buffers than the process can hold at
+// once and disables its core dumps, run it with make test-stress.
+func TestSecureMemoryRepeatedLookups(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: test_value
+`), LoadConfigOptions{LoadOptions: LoadOptions{SecureMemory: true}}))
+
+	// values that are never destroyed are released once collected, otherwise
+	// the mappings would run out long before the loop ends
+	for i := range 70_000 {
+		if i%1000 == 0 {
+			runtime.GC()
+		}
+
+		v, err := r.GetSecretValue(ctx, "test_secret")
+		require.NoError(t, err)
+		require.Equal(t, "test_value", v.Reveal())
+	}
+}
@@ -0,0 +1,116 @@
+package pakay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/pakay/internal/securemem"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue(t *testing.T) {
+	v := NewValue("s3cr3t")
+
+	require.Equal(t, "s3cr3t", v.Reveal())
+	require.Equal(t, []byte("s3cr3t"), v.Bytes())
+	require.False(t, v.IsEmpty())
+	require.True(t, Value{}.IsEmpty())
+
+	t.Run("fmt", func(t *testing.T) {
+		for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q", "%x", "%10s", "%d"} {
+			out := fmt.Sprintf(format, v)
+			require.NotContains(t, out, "s3cr3t", format)
+			require.Contains(t, out, "REDACTED", format)
+		}
+
+		require.Equal(t, "pakay.Value([REDACTED])", fmt.Sprintf("%#v", v))
+		require.Equal(t, "{[REDACTED]}", fmt.Sprintf("%v", struct{ V Value }{v}))
+	})
+
+	t.Run("slog", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		slog.New(slog.NewJSONHandler(buf, nil)).Info("msg", "secret", v)
+		require.NotContains(t, buf.String(), "s3cr3t")
+		require.Contains(t, buf.String(), `"secret":"[REDACTED]"`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := json.Marshal(map[string]any{"secret": v})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"secret":"[REDACTED]"}`, string(out))
+	})
+
+	t.Run("text", func(t *testing.T) {
+		out, err := v.MarshalText()
+		require.NoError(t, err)
+		require.Equal(t, "[REDACTED]", string(out))
+	})
+}
+
+func TestGetSecretValue(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: test_value
+`)))
+
+	v, err := r.GetSecretValue(context.Background(), "test_secret")
+	require.NoError(t, err)
+	require.Equal(t, "test_value", v.Reveal())
+	require.Equal(t, "[REDACTED]", v.String())
+
+	_, err = r.GetSecretValue(context.Background(), "unknown_secret")
+	require.ErrorIs(t, err, ErrUnknownSecret)
+}
+
+func TestSecureMemory(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry()
+	require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: test_value
+`), LoadConfigOptions{LoadOptions: LoadOptions{SecureMemory: true, Cache: CacheOptions{TTL: time.Minute}}}))
+
+	v, err := r.GetSecretValue(ctx, "test_secret")
+	require.NoError(t, err)
+	require.NotNil(t, v.buf)
+	require.Equal(t, "test_value", v.Reveal())
+	require.False(t, v.IsEmpty())
+
+	v.Destroy()
+	require.Empty(t, v.Reveal())
+	require.Nil(t, v.Bytes())
+	require.True(t, v.IsEmpty())
+
+	// the cached value is held apart from the returned ones
+	c, ok := r.cache.Get("test_secret")
+	require.True(t, ok)
+	require.NotNil(t, c.buf)
+	require.Empty(t, c.Value)
+
+	v, err = r.GetSecretValue(ctx, "test_secret")
+	require.NoError(t, err)
+	require.Equal(t, "test_value", v.Reveal())
+	v.Destroy()
+
+	// evicted values are wiped
+	r.Invalidate("test_secret")
+	_, err = c.buf.Copy()
+	require.ErrorIs(t, err, securemem.ErrDestroyed)
+
+	val, err := r.GetSecretE(ctx, "test_secret")
+	require.NoError(t, err)
+	require.Equal(t, "test_value", val)
+}
@@ -4,54 +4,111 @@ import (
 	"context"
 
 	"github.com/jcchavezs/pakay"
-	"github.com/jcchavezs/pakay/internal/secrets"
 )
 
 type Secret interface {
 	Name() string
 	Description() string
+	// Sources describes the sources of the secret, flagging the ones whose condition
+	// doesn't hold as "skipped (condition false)".
 	Sources() []string
+	// Required is false for the secrets that can be missing.
+	Required() bool
+	// Default returns the value used when none of the sources returns one.
+	Default() (string, bool)
+	// Dependencies returns the secrets a derived secret is composed from.
+	Dependencies() []string
+	// Aliases returns the former names of the secret.
+	Aliases() []string
+	// Deprecated returns the deprecation of the secret, if deprecated.
+	Deprecated() (pakay.Deprecation, bool)
 	GetValue(ctx context.Context) (string, bool)
+	// GetValueWithSource returns the value along with the source that resolved it.
+	GetValueWithSource(ctx context.Context) (string, pakay.SourceInfo, bool)
 }
 
 type secret struct {
-	filterIn pakay.FilterIn
-	secrets.Secret
+	registry *pakay.Registry
+	opts     pakay.SecretOptions
+	info     pakay.SecretInfo
 }
 
 var _ Secret = secret{}
 
 func (ss secret) Name() string {
-	return ss.Secret.Name
+	return ss.info.Name
 }
 
 func (ss secret) Description() string {
-	return ss.Secret.Description
+	return ss.info.Description
+}
+
+func (ss secret) Required() bool {
+	return ss.info.Required
+}
+
+func (ss secret) Default() (string, bool) {
+	if ss.info.Default == nil {
+		return "", false
+	}
+
+	return *ss.info.Default, true
+}
+
+func (ss secret) Dependencies() []string {
+	return ss.info.Dependencies
+}
+
+func (ss secret) Aliases() []string {
+	return ss.info.Aliases
+}
+
+func (ss secret) Deprecated() (pakay.Deprecation, bool) {
+	if ss.info.Deprecated == nil {
+		return pakay.Deprecation{}, false
+	}
+
+	return *ss.info.Deprecated, true
 }
 
 func (ss secret) Sources() []string {
-	sources := make([]string, 0, len(ss.Secret.Sources))
-	for _, s := range ss.Secret.Sources {
-		if ss.filterIn != nil {
-			if !ss.filterIn(pakay.Source{Type: s.Type, Labels: s.Labels}) {
-				continue
-			}
+	sources := make([]string, 0, len(ss.info.Sources))
+	for _, s := range ss.info.Sources {
+		if s.Condition != nil && s.Condition() != nil {
+			sources = append(sources, s.Description+" - skipped (condition false)")
+			continue
 		}
 
-		sources = append(sources, s.String())
+		sources = append(sources, s.Description)
 	}
 
 	return sources
 }
 
 func (ss secret) GetValue(ctx context.Context) (string, bool) {
-	return pakay.GetSecretWithOptions(ctx, ss.Secret.Name, pakay.SecretOptions{
-		FilterIn: ss.filterIn,
-	})
+	return ss.registry.GetSecretWithOptions(ctx, ss.info.Name, ss.opts)
+}
+
+func (ss secret) GetValueWithSource(ctx context.Context) (string, pakay.SourceInfo, bool) {
+	res, err := ss.registry.ResolveSecretWithOptions(ctx, ss.info.Name, ss.opts)
+	if err != nil {
+		return "", pakay.SourceInfo{}, false
+	}
+
+	return res.Value, res.Source, true
 }
 
 type ListOptions struct {
 	FilterIn pakay.FilterIn
+	// Selector is a label selector applied on top of FilterIn, see
+	// pakay.ParseSelector. An invalid selector lists no sources.
+	Selector string
+	// Profile lists the sources of one of the profiles declared in the manifest
+	// instead of the ones of the registry profile. An unknown profile lists no
+	// sources.
+	Profile string
+	// Registry to list the secrets from. Defaults to the package level registry.
+	Registry *pakay.Registry
 }
 
 // ListSecrets returns the status of all secrets
@@ -61,12 +118,30 @@ func ListSecrets(ctx context.Context) []Secret {
 
 // ListSecretsWithOptions returns the status of secrets, applying the provided filter if any.
 func ListSecretsWithOptions(ctx context.Context, opts ListOptions) []Secret {
-	ss := make([]Secret, 0, len(secrets.All))
+	r := opts.Registry
+	if r == nil {
+		r = pakay.Default()
+	}
+
+	infos, err := r.DescribeSecrets(pakay.DescribeOptions{
+		FilterIn: opts.FilterIn,
+		Selector: opts.Selector,
+		Profile:  opts.Profile,
+	})
+	if err != nil {
+		return []Secret{}
+	}
 
-	for _, s := range secrets.All {
+	ss := make([]Secret, 0, len(infos))
+	for _, info := range infos {
 		ss = append(ss, secret{
-			filterIn: opts.FilterIn,
-			Secret:   s,
+			registry: r,
+			opts: pakay.SecretOptions{
+				FilterIn: opts.FilterIn,
+				Selector: opts.Selector,
+				Profile:  opts.Profile,
+			},
+			info: info,
 		})
 	}
 
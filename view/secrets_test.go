@@ -2,7 +2,9 @@ package view
 
 import (
 	"context"
+	"fmt"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/jcchavezs/pakay"
@@ -28,6 +30,8 @@ func TestListSecrets(t *testing.T) {
     env:
       key: TEST_ENV_VAR_2
 - name: test_secret_3
+  required: false
+  default: default_value
   sources:
   - type: env
     labels: [deprecated]
@@ -63,11 +67,231 @@ func TestListSecrets(t *testing.T) {
 			v, ok = s.GetValue(ctx)
 			require.Equal(t, "my_value", v)
 			require.True(t, ok)
+
+			v, src, ok := s.GetValueWithSource(ctx)
+			require.True(t, ok)
+			require.Equal(t, "my_value", v)
+			require.Equal(t, pakay.SourceInfo{Index: 0, Type: "env", Description: "env: TEST_ENV_VAR_1"}, src)
 		case "test_secret_2":
 			require.Len(t, s.Sources(), 1)
 			require.Equal(t, "env: TEST_ENV_VAR_2", s.Sources()[0])
+			require.True(t, s.Required())
+			_, ok := s.Default()
+			require.False(t, ok)
 		case "test_secret_3":
 			require.Len(t, s.Sources(), 0)
+			require.False(t, s.Required())
+			def, ok := s.Default()
+			require.True(t, ok)
+			require.Equal(t, "default_value", def)
+
+			v, ok := s.GetValue(ctx)
+			require.True(t, ok)
+			require.Equal(t, "default_value", v)
 		}
 	}
 }
+
+func TestListSecretsWhileLoading(t *testing.T) {
+	r := pakay.NewRegistry()
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			require.NoError(t, r.LoadSecretsConfig([]byte(fmt.Sprintf(`---
+- name: secret_%d
+  sources:
+  - type: static
+    static:
+      value: my_value
+`, i))))
+		}
+	}()
+
+	for {
+		for _, s := range ListSecretsWithOptions(ctx, ListOptions{Registry: r}) {
+			require.Len(t, s.Sources(), 1)
+			v, ok := s.GetValue(ctx)
+			require.True(t, ok)
+			require.Equal(t, "my_value", v)
+		}
+
+		select {
+		case <-done:
+			require.Len(t, ListSecretsWithOptions(ctx, ListOptions{Registry: r}), 20)
+			return
+		default:
+		}
+	}
+}
+
+func TestListDerivedSecrets(t *testing.T) {
+	r := pakay.NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: db_user
+  sources:
+  - type: static
+    static:
+      value: admin
+- name: db_password
+  sources:
+  - type: env
+    env:
+      key: TEST_VIEW_DB_PASSWORD
+- name: db_dsn
+  derived:
+    template: postgres://{{ secret "db_user" }}:{{ secret "db_password" }}@db/app
+`)))
+
+	ctx := context.Background()
+	ss := ListSecretsWithOptions(ctx, ListOptions{Registry: r})
+	require.Len(t, ss, 3)
+
+	dsn := ss[2]
+	require.Equal(t, []string{"db_user", "db_password"}, dsn.Dependencies())
+	require.Empty(t, dsn.Sources())
+	require.Empty(t, ss[0].Dependencies())
+
+	_, ok := dsn.GetValue(ctx)
+	require.False(t, ok)
+
+	t.Setenv("TEST_VIEW_DB_PASSWORD", "s3cr3t")
+	v, src, ok := dsn.GetValueWithSource(ctx)
+	require.True(t, ok)
+	require.Equal(t, "postgres://admin:s3cr3t@db/app", v)
+	require.Equal(t, "derived", src.Type)
+}
+
+func TestListDeprecatedSecrets(t *testing.T) {
+	r := pakay.NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  aliases: [old_api_token]
+  sources:
+  - type: static
+    static:
+      value: my_value
+- name: legacy_token
+  deprecated:
+    since: v1.2.0
+    replacement: api_token
+  sources:
+  - type: static
+    static:
+      value: my_value
+`)))
+
+	ss := ListSecretsWithOptions(context.Background(), ListOptions{Registry: r})
+	require.Len(t, ss, 2)
+
+	require.Equal(t, []string{"old_api_token"}, ss[0].Aliases())
+	_, ok := ss[0].Deprecated()
+	require.False(t, ok)
+
+	d, ok := ss[1].Deprecated()
+	require.True(t, ok)
+	require.Equal(t, pakay.Deprecation{Since: "v1.2.0", Replacement: "api_token"}, d)
+}
+
+func TestListSecretsWithProfile(t *testing.T) {
+	r := pakay.NewRegistry()
+	require.NoError(t, r.LoadSecretsConfigWithOptions([]byte(`---
+profiles:
+  local:
+    labels: [local]
+  ci:
+    labels: [ci]
+secrets:
+- name: api_token
+  sources:
+  - type: env
+    labels: [local]
+    env:
+      key: TEST_VIEW_LOCAL_TOKEN
+  - type: env
+    labels: [ci]
+    env:
+      key: TEST_VIEW_CI_TOKEN
+`), pakay.LoadConfigOptions{LoadOptions: pakay.LoadOptions{Profile: "local"}}))
+
+	t.Setenv("TEST_VIEW_LOCAL_TOKEN", "local_value")
+	t.Setenv("TEST_VIEW_CI_TOKEN", "ci_value")
+
+	ctx := context.Background()
+	for profile, expected := range map[string]string{"": "LOCAL", "local": "LOCAL", "ci": "CI"} {
+		ss := ListSecretsWithOptions(ctx, ListOptions{Registry: r, Profile: profile})
+		require.Len(t, ss, 1)
+		require.Equal(t, []string{"env: TEST_VIEW_" + expected + "_TOKEN"}, ss[0].Sources())
+
+		v, ok := ss[0].GetValue(ctx)
+		require.True(t, ok)
+		require.Equal(t, strings.ToLower(expected)+"_value", v)
+	}
+
+	ss := ListSecretsWithOptions(ctx, ListOptions{Registry: r, Profile: "prod"})
+	require.Empty(t, ss[0].Sources())
+}
+
+func TestListSecretsWithSelector(t *testing.T) {
+	r := pakay.NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  sources:
+  - type: env
+    labels: [env=local]
+    env:
+      key: TEST_VIEW_SELECTOR_LOCAL_TOKEN
+  - type: env
+    labels: [env=ci]
+    env:
+      key: TEST_VIEW_SELECTOR_CI_TOKEN
+`)))
+
+	t.Setenv("TEST_VIEW_SELECTOR_LOCAL_TOKEN", "local_value")
+	t.Setenv("TEST_VIEW_SELECTOR_CI_TOKEN", "ci_value")
+
+	ctx := context.Background()
+	ss := ListSecretsWithOptions(ctx, ListOptions{Registry: r, Selector: "env notin (local)"})
+	require.Len(t, ss, 1)
+	require.Equal(t, []string{"env: TEST_VIEW_SELECTOR_CI_TOKEN"}, ss[0].Sources())
+
+	v, ok := ss[0].GetValue(ctx)
+	require.True(t, ok)
+	require.Equal(t, "ci_value", v)
+
+	ss = ListSecretsWithOptions(ctx, ListOptions{Registry: r, Selector: "env in ("})
+	require.Empty(t, ss[0].Sources())
+
+	_, ok = ss[0].GetValue(ctx)
+	require.False(t, ok)
+}
+
+func TestListSecretsWithCondition(t *testing.T) {
+	r := pakay.NewRegistry()
+	require.NoError(t, r.LoadSecretsConfig([]byte(`---
+- name: api_token
+  sources:
+  - type: env
+    when:
+      env_set: [TEST_VIEW_CONDITION_CI]
+    env:
+      key: TEST_VIEW_CONDITION_TOKEN
+  - type: env
+    env:
+      key: TEST_VIEW_CONDITION_LOCAL_TOKEN
+`)))
+
+	ss := ListSecretsWithOptions(context.Background(), ListOptions{Registry: r})
+	require.Equal(t, []string{
+		"env: TEST_VIEW_CONDITION_TOKEN - skipped (condition false)",
+		"env: TEST_VIEW_CONDITION_LOCAL_TOKEN",
+	}, ss[0].Sources())
+
+	t.Setenv("TEST_VIEW_CONDITION_CI", "true")
+	require.Equal(t, []string{
+		"env: TEST_VIEW_CONDITION_TOKEN",
+		"env: TEST_VIEW_CONDITION_LOCAL_TOKEN",
+	}, ss[0].Sources())
+}
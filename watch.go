@@ -0,0 +1,92 @@
+package pakay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultWatchInterval = 2 * time.Second
+
+type WatchOptions struct {
+	// Interval between checks of the manifest file. Defaults to 2 seconds.
+	Interval time.Duration
+	// OnError is called when the manifest cannot be read or a changed manifest cannot
+	// be loaded, once per read error or content until it changes. The previously
+	// loaded secrets remain in place.
+	OnError func(error)
+	LoadConfigOptions
+}
+
+// WatchSecretsConfig loads the manifest at the given path and keeps polling it for
+// changes until ctx is done. Every time the content changes the manifest is parsed,
+// validated and swapped in atomically as in Reload.
+func (r *Registry) WatchSecretsConfig(ctx context.Context, path string, opts WatchOptions) error {
+	loaded, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %w", err)
+	}
+
+	if err := r.ReloadWithOptions(loaded, opts.LoadConfigOptions); err != nil {
+		return err
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	onError := func(err error) {
+		r.log().Error("Failed to reload secrets manifest", "path", path, "error", err)
+		if opts.OnError != nil {
+			opts.OnError(err)
+		}
+	}
+
+	go func() {
+		attempted := loaded
+		var readErr string
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			config, err := os.ReadFile(path)
+			if err != nil {
+				if err.Error() != readErr {
+					readErr = err.Error()
+					onError(fmt.Errorf("reading manifest: %w", err))
+				}
+				continue
+			}
+			readErr = ""
+
+			// attempted is tracked apart from loaded so an invalid manifest is
+			// reported once rather than on every tick until it is fixed
+			if bytes.Equal(config, attempted) {
+				continue
+			}
+			attempted = config
+
+			if bytes.Equal(config, loaded) {
+				continue
+			}
+
+			if err := r.ReloadWithOptions(config, opts.LoadConfigOptions); err != nil {
+				onError(err)
+				continue
+			}
+
+			loaded = config
+		}
+	}()
+
+	return nil
+}
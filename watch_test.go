@@ -0,0 +1,88 @@
+package pakay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchSecretsConfig(t *testing.T) {
+	manifest := func(value string) []byte {
+		return []byte(`---
+- name: test_secret
+  sources:
+  - type: static
+    static:
+      value: ` + value + `
+`)
+	}
+
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	require.NoError(t, writeFile(t, path, manifest("first_value")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRegistry()
+	events := make(chan ChangeEvent, 10)
+	r.Subscribe(func(ev ChangeEvent) { events <- ev })
+
+	errs := make(chan error, 10)
+	err := r.WatchSecretsConfig(ctx, path, WatchOptions{
+		Interval: 10 * time.Millisecond,
+		OnError:  func(err error) { errs <- err },
+	})
+	require.NoError(t, err)
+	require.Equal(t, ChangeEvent{Added: []string{"test_secret"}}, <-events)
+
+	val, ok := r.GetSecret(ctx, "test_secret")
+	require.True(t, ok)
+	require.Equal(t, "first_value", val)
+
+	require.NoError(t, writeFile(t, path, manifest("second_value")))
+	require.Equal(t, ChangeEvent{Changed: []string{"test_secret"}}, <-events)
+
+	val, ok = r.GetSecret(ctx, "test_secret")
+	require.True(t, ok)
+	require.Equal(t, "second_value", val)
+
+	require.NoError(t, writeFile(t, path, []byte("- name: [invalid")))
+	require.ErrorContains(t, <-errs, "parsing manifest")
+	require.Never(t, func() bool { return len(errs) > 0 }, 100*time.Millisecond, 10*time.Millisecond,
+		"the same invalid manifest is reported once")
+
+	val, ok = r.GetSecret(ctx, "test_secret")
+	require.True(t, ok)
+	require.Equal(t, "second_value", val)
+
+	require.NoError(t, os.Remove(path))
+	require.ErrorContains(t, <-errs, "reading manifest")
+	require.Never(t, func() bool { return len(errs) > 0 }, 100*time.Millisecond, 10*time.Millisecond,
+		"the same read error is reported once")
+
+	require.NoError(t, writeFile(t, path, manifest("third_value")))
+	require.Equal(t, ChangeEvent{Changed: []string{"test_secret"}}, <-events)
+
+	val, ok = r.GetSecret(ctx, "test_secret")
+	require.True(t, ok)
+	require.Equal(t, "third_value", val)
+}
+
+// writeFile replaces the file at once so the watcher never reads it half written.
+func writeFile(t *testing.T, path string, data []byte) error {
+	tmp := filepath.Join(t.TempDir(), filepath.Base(path))
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func TestWatchSecretsConfigMissingFile(t *testing.T) {
+	err := NewRegistry().WatchSecretsConfig(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"), WatchOptions{})
+	require.ErrorContains(t, err, "reading manifest")
+}